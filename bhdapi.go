@@ -6,6 +6,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -435,16 +438,23 @@ func (req SearchRequest) WithPage(page int) *SearchRequest {
 
 // Do executes the search request against the client.
 func (req *SearchRequest) Do(ctx context.Context, cl *Client) (*SearchResponse, error) {
+	ctx, span := cl.start(ctx, "bhdapi.SearchRequest.Do", slog.String("bhd.action", "search"), slog.Int("bhd.page", req.Page))
+	res, err := req.do(ctx, cl)
+	span.End(err)
+	return res, err
+}
+
+// do executes the search request against the client, recording the result
+// counts on the span in the context.
+func (req *SearchRequest) do(ctx context.Context, cl *Client) (*SearchResponse, error) {
 	res := new(SearchResponse)
-	if err := cl.Do(ctx, "search", req, res); err != nil {
+	if err := cl.DoParams(ctx, req, res); err != nil {
 		return nil, err
 	}
-	switch {
-	case res.StatusMessage != "":
-		return nil, errors.New(res.StatusMessage)
-	case !res.Success:
-		return nil, errors.New("success != true")
+	if err := statusErr(res.Success, res.StatusMessage); err != nil {
+		return nil, err
 	}
+	spanAttrs(ctx, slog.Int("bhd.results", len(res.Results)), slog.Int("bhd.total_pages", res.TotalPages), slog.Int("bhd.total_results", res.TotalResults))
 	return res, nil
 }
 
@@ -480,8 +490,14 @@ func (req *SearchRequest) Next(ctx context.Context, cl *Client) bool {
 		}
 	}
 	req.p, req.i = req.p+1, 0
-	req.res, req.err = req.WithPage(page+req.p).Do(ctx, cl)
-	return req.err == nil && req.i < len(req.res.Results)
+	ctx, span := cl.start(ctx, "bhdapi.SearchRequest.Next", slog.String("bhd.action", "search"), slog.Int("bhd.page", page+req.p))
+	req.res, req.err = req.WithPage(page+req.p).do(ctx, cl)
+	span.End(req.err)
+	if req.err != nil {
+		return false
+	}
+	cl.log(ctx, slog.LevelDebug, "search page", "page", req.res.Page, "total_pages", req.res.TotalPages, "results", len(req.res.Results))
+	return req.i < len(req.res.Results)
 }
 
 // Cur returns the search response cursor's current torrent. Returns the same
@@ -612,6 +628,33 @@ type Torrent struct {
 	DownloadURL string `json:"download_url,omitempty"`
 }
 
+// Magnet returns a magnet link for the torrent, built from its info hash, name
+// and size, with the trackers as tr params. Returns an empty string when the
+// torrent has no info hash. Use Client.AnnounceURL to retrieve the personal
+// announce url.
+func (t Torrent) Magnet(trackers ...string) string {
+	if t.InfoHash == "" {
+		return ""
+	}
+	s := "magnet:?xt=urn:btih:" + strings.ToLower(t.InfoHash)
+	if t.Name != "" {
+		s += "&dn=" + magnetEscape(t.Name)
+	}
+	if t.Size != 0 {
+		s += "&xl=" + strconv.FormatInt(t.Size, 10)
+	}
+	for _, tracker := range trackers {
+		s += "&tr=" + magnetEscape(tracker)
+	}
+	return s
+}
+
+// magnetEscape escapes s for a magnet link param, escaping spaces as %20, as
+// not all clients decode + as a space.
+func magnetEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
 // Bool is a bool type.
 type Bool bool
 
@@ -679,3 +722,14 @@ func (t *Time) UnmarshalJSON(buf []byte) error {
 
 // timefmt is the time format used for parsing and display time values.
 const timefmt = "2006-01-02 15:04:05"
+
+// statusErr returns an error for an unsuccessful api response.
+func statusErr(success bool, msg string) error {
+	switch {
+	case msg != "":
+		return errors.New(msg)
+	case !success:
+		return errors.New("success != true")
+	}
+	return nil
+}
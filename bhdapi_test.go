@@ -3,8 +3,22 @@ package bhdapi
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+
+	"github.com/moistari/bhdapi/bhdtest"
+	"github.com/moistari/bhdapi/metainfo"
 )
 
 func TestSearch(t *testing.T) {
@@ -48,6 +62,665 @@ func TestTorrent(t *testing.T) {
 	}
 }
 
+func TestDoParams(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := New(WithApiKey(srv.ApiKey), WithRssKey(srv.RssKey, false), WithTransport(srv.Transport()))
+	ctx := context.Background()
+	res := new(SearchResponse)
+	if err := cl.Do(ctx, "search", &struct {
+		ImdbID string `json:"imdb_id"`
+	}{"tt0137523"}, res); err != nil || res.TotalResults != 3 {
+		t.Errorf("expected 3 results, got: %+v %v", res, err)
+	}
+	if err := cl.Do(ctx, "search", SearchRequest{Search: "fight club"}, res); err == nil {
+		t.Errorf("expected error for non-pointer params")
+	}
+	if n := srv.Requests("search"); n != 1 {
+		t.Errorf("expected 1 search request, got: %d", n)
+	}
+}
+
+func TestMagnet(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := New(WithApiKey(srv.ApiKey), WithRssKey(srv.RssKey, false), WithTransport(srv.Transport()))
+	ctx := context.Background()
+	res, err := Search().WithInfoHash(srv.Torrents()[0]["info_hash"].(string)).Do(ctx, cl)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(res.Results) != 1 {
+		t.Fatalf("expected 1 result, got: %d", len(res.Results))
+	}
+	torrent := res.Results[0]
+	announce, err := cl.AnnounceURL(ctx, torrent.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if exp := "https://tracker.beyond-hd.me:2053/announce/" + srv.Passkey; announce != exp {
+		t.Errorf("expected announce url %q, got: %q", exp, announce)
+	}
+	u, err := url.Parse(torrent.Magnet(announce))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	q := u.Query()
+	switch {
+	case u.Scheme != "magnet":
+		t.Errorf("expected magnet scheme, got: %q", u.Scheme)
+	case q.Get("xt") != "urn:btih:"+torrent.InfoHash:
+		t.Errorf("expected xt urn:btih:%s, got: %q", torrent.InfoHash, q.Get("xt"))
+	case q.Get("dn") != torrent.Name:
+		t.Errorf("expected dn %q, got: %q", torrent.Name, q.Get("dn"))
+	case q.Get("xl") != strconv.FormatInt(torrent.Size, 10):
+		t.Errorf("expected xl %d, got: %q", torrent.Size, q.Get("xl"))
+	case q.Get("tr") != announce:
+		t.Errorf("expected tr %q, got: %q", announce, q.Get("tr"))
+	}
+	if s, exp := (Torrent{InfoHash: "ABC", Name: "Fight Club+ 1999"}).Magnet(), "magnet:?xt=urn:btih:abc&dn=Fight%20Club%2B%201999"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	if s := (Torrent{Name: "x"}).Magnet(); s != "" {
+		t.Errorf("expected empty magnet without info hash, got: %q", s)
+	}
+	if _, err := cl.AnnounceURL(ctx, 1); err == nil {
+		t.Errorf("expected error for unknown torrent")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := New(WithApiKey(srv.ApiKey), WithTransport(srv.Transport()))
+	dir := filepath.Join(t.TempDir(), "Home.Video.2024.1080p")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "video.mkv"), bytes.Repeat([]byte("bhd"), 100_000), 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ctx := context.Background()
+	buf, err := metainfo.Create(ctx, dir, metainfo.CreateOptions{Private: true, Source: "BHD"})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	m, err := metainfo.Parse(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	res, err := cl.Lookup(ctx, m)
+	if err != nil || len(res.Results) != 0 || res.Exact {
+		t.Fatalf("expected no results, got: %+v %v", res, err)
+	}
+	// torrents matching only the size are not returned
+	srv.Add(bhdtest.Torrent{"id": 190000, "name": "Other Video 2024 1080p", "folder_name": "Other.Video.2024.1080p", "size": m.Size()})
+	res, err = cl.Lookup(ctx, m)
+	if err != nil || len(res.Results) != 0 || res.Exact {
+		t.Fatalf("expected no results, got: %+v %v", res, err)
+	}
+	// the server generates a different info hash for the torrent
+	torrent := bhdtest.Torrent{"id": 190001, "name": "Home Video 2024 1080p", "folder_name": "Home.Video.2024.1080p", "size": m.Size()}
+	srv.Add(torrent)
+	res, err = cl.Lookup(ctx, m)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case res.Exact || len(res.Results) != 1 || res.Results[0].ID != 190001:
+		t.Errorf("expected inexact match 190001, got: %+v", res)
+	}
+	torrent["info_hash"] = m.InfoHash()
+	res, err = cl.Lookup(ctx, m)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case !res.Exact || res.InfoHash != m.InfoHash() || len(res.Results) != 1 || res.Results[0].ID != 190001:
+		t.Errorf("expected exact match 190001, got: %+v", res)
+	}
+}
+
+func TestTitles(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := New(WithApiKey(srv.ApiKey), WithTransport(srv.Transport()))
+	torrents, err := Search().All(context.Background(), cl)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	torrents = append(torrents,
+		Torrent{ID: 1, Name: "Heat.1995.1080p.BluRay.x264-GRP", Type: "1080p", Size: 10, Seeders: 3},
+		Torrent{ID: 2, Name: "Heat 1995 UHD BluRay 2160p HEVC REMUX-GRP", Type: "UHD Remux", TmdbID: "949", Size: 50, HLG: true},
+		Torrent{ID: 3, Name: "Heat (1995) 720p", Type: "720p", ImdbID: "tt0113277", TmdbID: "949", Size: 5},
+		Torrent{ID: 4, Name: "Unknown.Release-GRP", Type: "Other"},
+		Torrent{ID: 5, Name: "The.Office.S01.1080p.WEB-DL.DD+.5.1.H.264-GRP", Type: "1080p", ImdbID: "tt0386676", Size: 8},
+		Torrent{ID: 6, Name: "The Office S01 1080p BluRay DD 2.0 x264-GRP", Type: "1080p", ImdbID: "tt0290978", Size: 6},
+		Torrent{ID: 7, Name: "Blade.Runner.2049.2017.2160p.UHD.BluRay.REMUX.HDR.HEVC.Atmos-GRP", Type: "UHD Remux", Size: 60, HDR10: true},
+	)
+	var b strings.Builder
+	for _, title := range Titles(torrents) {
+		fmt.Fprintf(&b, "%s|%d|%s|%s|%d|%d|%d|%d|%v\n", title.Name, title.Year, title.ImdbID, title.TmdbID, title.Count, title.Seeders, title.MinSize, title.MaxSize, title.Features)
+		for _, e := range title.Editions {
+			fmt.Fprintf(&b, "  %s|%d|%d\n", e.Resolution, e.Count, e.Seeders)
+			for _, s := range e.Sets {
+				var ids []int
+				for _, torrent := range s.Torrents {
+					ids = append(ids, torrent.ID)
+				}
+				fmt.Fprintf(&b, "    %s|%v|%v\n", s.Type, ids, s.Features)
+			}
+		}
+	}
+	// search results are ordered by bumped_at, newest first
+	exp := `Severance|0|tt11280740|95396|1|58|53687091200|53687091200|[DV HDR10]
+  2160p|1|58
+    2160p|[180777]|[DV HDR10]
+The Matrix|1999|tt0133093|603|2|302|4294967296|68719476736|[HDR10 HDR10P]
+  2160p|1|302
+    UHD Remux|[162200]|[HDR10 HDR10P]
+  720p|1|4
+    720p|[170555]|[]
+Fight Club|1999|tt0137523|550|3|241|12884901888|71263782400|[DV HDR10 Commentary]
+  2160p|1|241
+    UHD Remux|[101233]|[DV HDR10 Commentary]
+  1080p|2|112
+    BD Remux|[7531]|[Commentary]
+    1080p|[150012]|[]
+Heat|1995|tt0113277|949|3|3|5|50|[HLG]
+  2160p|1|0
+    UHD Remux|[2]|[HLG]
+  1080p|1|3
+    1080p|[1]|[]
+  720p|1|0
+    720p|[3]|[]
+Unknown Release-GRP|0|||1|0|0|0|[]
+  |1|0
+    Other|[4]|[]
+The Office|0|tt0386676||1|0|8|8|[]
+  1080p|1|0
+    1080p|[5]|[]
+The Office|0|tt0290978||1|0|6|6|[]
+  1080p|1|0
+    1080p|[6]|[]
+Blade Runner 2049|2017|||1|0|60|60|[HDR10]
+  2160p|1|0
+    UHD Remux|[7]|[HDR10]
+`
+	if s := b.String(); s != exp {
+		t.Errorf("expected:\n%s\ngot:\n%s", exp, s)
+	}
+	for _, test := range []struct {
+		name string
+		exp  string
+		year int
+	}{
+		{"The.Matrix.1999.720p.WEB-DL.DD.5.1.H.264-NTb", "The Matrix", 1999},
+		{"Severance S01E02 2160p WEB-DL", "Severance", 0},
+		{"2001 A Space Odyssey 1968 1080p", "2001 A Space Odyssey", 1968},
+		{"Blade.Runner.2049.2017.2160p.UHD.BluRay.REMUX-GRP", "Blade Runner 2049", 2017},
+		{"1917 2019 1080p BluRay", "1917", 2019},
+		{"Heat (1995) 720p", "Heat", 1995},
+		{"Fight Club 1999 BluRay 2020 Remaster", "Fight Club", 1999},
+		{"Some Movie 1080p WEB-DL", "Some Movie", 0},
+	} {
+		if name, year := ParseTitle(test.name); name != test.exp || year != test.year {
+			t.Errorf("%q expected %q %d, got: %q %d", test.name, test.exp, test.year, name, year)
+		}
+	}
+}
+
+func TestLoadCredentialsFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.toml")
+	config := `# bhd
+api_key = "apisecret"
+rss_key_command = 'echo rsssecret'
+`
+	if err := os.WriteFile(name, []byte(config), 0o600); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	creds, err := LoadCredentialsFile(context.Background(), name)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if creds.ApiKey != "apisecret" {
+		t.Errorf("expected api key %q, got: %q", "apisecret", creds.ApiKey)
+	}
+	if creds.RssKey != "rsssecret" {
+		t.Errorf("expected rss key %q, got: %q", "rsssecret", creds.RssKey)
+	}
+	for _, s := range []string{creds.String(), fmt.Sprintf("%v %+v %#v", creds, creds, creds)} {
+		if strings.Contains(s, "secret") {
+			t.Errorf("expected keys to be redacted, got: %s", s)
+		}
+	}
+	// keys set in the environment do not run commands
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv(EnvApiKey, "envapi")
+	t.Setenv(EnvRssKey, "envrss")
+	if err := os.MkdirAll(filepath.Join(dir, "bhdapi"), 0o755); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	config = "api_key_command = 'echo failed >&2; exit 1'\nrss_key_command = 'exit 1'\n"
+	if err := os.WriteFile(filepath.Join(dir, "bhdapi", "config.toml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	creds, err = LoadCredentials(context.Background())
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case creds.ApiKey != "envapi" || creds.RssKey != "envrss":
+		t.Errorf("expected environment keys, got: %q %q", creds.ApiKey, creds.RssKey)
+	}
+	t.Setenv(EnvApiKey, "")
+	if _, err := LoadCredentials(context.Background()); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected error with command error output, got: %v", err)
+	}
+	for config, ok := range map[string]bool{
+		`api_key = "a\"b" # comment`: true,
+		`api_key = 'a' `:             true,
+		`api_key = "a" b`:            false,
+		`api_key = 'a' 'b'`:          false,
+		`api_key = "a`:               false,
+		"[bhd]\napi_key = 'a'":       false,
+	} {
+		if _, err := parseConfig([]byte(config)); (err == nil) != ok {
+			t.Errorf("%q expected ok %t, got: %v", config, ok, err)
+		}
+	}
+}
+
+func TestSaveSearch(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+	req := Search("!cam").
+		WithTypes("UHD Remux", "2160p").
+		WithFeatures("DV").
+		WithFreeleech(true).
+		WithMinYear(2019).
+		WithSize(81604378624).
+		WithSort("seeders")
+	if err := SaveSearch("uhd-dv-freeleech", req); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	buf, err := MarshalSearch(req, "json")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "bhdapi", "searches", "remux.json"), buf, 0o644); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	names, err := ListSearches()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := strings.Join(names, ","); s != "remux,uhd-dv-freeleech" {
+		t.Errorf("expected names remux,uhd-dv-freeleech, got: %s", s)
+	}
+	for _, name := range names {
+		saved, err := LoadSearch(name)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got: %v", name, err)
+		}
+		for _, format := range []string{"json", "yaml"} {
+			exp, _ := MarshalSearch(req, format)
+			buf, err := MarshalSearch(saved, format)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got: %v", name, err)
+			}
+			if !bytes.Equal(buf, exp) {
+				t.Errorf("%s: expected %s:\n%s\ngot:\n%s", name, format, exp, buf)
+			}
+		}
+		if p, i := saved.PageIndex(); p != -1 || i != -1 {
+			t.Errorf("%s: expected unstarted search, got page %d index %d", name, p, i)
+		}
+	}
+	if _, err := UnmarshalSearch([]byte("freeleach: true\n"), "yaml"); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if err := SaveSearch("../escape", req); err == nil {
+		t.Errorf("expected error for invalid name")
+	}
+	if err := DeleteSearch("remux"); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := LoadSearch("remux"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got: %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	req := Search()
+	if m := req.Encode(); len(m) != 1 || m["page"] != 1 {
+		t.Errorf("expected only page to be encoded, got: %v", m)
+	}
+	v := reflect.ValueOf(req).Elem()
+	exp := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.SplitN(v.Type().Field(i).Tag.Get("json"), ",", 2)[0]
+		if tag == "" {
+			continue
+		}
+		switch f := v.Field(i); f.Interface().(type) {
+		case []string:
+			f.Set(reflect.ValueOf([]string{tag, "b"}))
+			exp[tag] = tag + ",b"
+		case string:
+			f.SetString(tag)
+			exp[tag] = tag
+		case Bool:
+			f.SetBool(true)
+			exp[tag] = 1
+		case int:
+			f.SetInt(int64(i))
+			exp[tag] = i
+		case int64:
+			f.SetInt(int64(i))
+			exp[tag] = int64(i)
+		default:
+			t.Fatalf("field %s has unsupported type %T, update gen.go", tag, f.Interface())
+		}
+	}
+	if m := req.Encode(); !reflect.DeepEqual(m, exp) {
+		t.Errorf("expected %v, got: %v (run go generate)", exp, m)
+	}
+	if m, err := encode(req); err != nil || !reflect.DeepEqual(m, exp) {
+		t.Errorf("expected %v, got: %v %v", exp, m, err)
+	}
+}
+
+func TestSearchValues(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	str := func(comma bool) string {
+		const chars = "abcXYZ019 !&=?#%+-_.,/:\"'\u00e9\u65e5"
+		runes := []rune(chars)
+		var b strings.Builder
+		for n := r.Intn(12); n >= 0; n-- {
+			if c := runes[r.Intn(len(runes))]; c != ',' || comma {
+				b.WriteRune(c)
+			}
+		}
+		return b.String()
+	}
+	for n := 0; n < 1000; n++ {
+		req := Search()
+		v := reflect.ValueOf(req).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			f, field := v.Field(i), v.Type().Field(i)
+			if !field.IsExported() || r.Intn(3) == 0 {
+				continue
+			}
+			switch f.Interface().(type) {
+			case []string:
+				var list []string
+				for j := r.Intn(4); j >= 0; j-- {
+					if s := str(false); s != "" {
+						list = append(list, s)
+					}
+				}
+				f.Set(reflect.ValueOf(list))
+			case string:
+				f.SetString(str(true))
+			case Bool:
+				f.SetBool(r.Intn(2) == 0)
+			case int:
+				f.SetInt(int64(r.Int31()) - 1<<30)
+			case int64:
+				f.SetInt(r.Int63() - 1<<62)
+			default:
+				t.Fatalf("field %s has unsupported type %s", field.Name, field.Type)
+			}
+		}
+		if req.Page == 0 {
+			// zero page is the first page
+			req.Page = 1
+		}
+		q, err := url.ParseQuery(req.Values().Encode())
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		parsed, err := ParseSearchValues(q)
+		if err != nil {
+			t.Fatalf("expected no error for %v, got: %v", q, err)
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			exp, got := v.Field(i), reflect.ValueOf(parsed).Elem().Field(i)
+			if exp.Kind() == reflect.Slice && exp.Len() == 0 && got.Len() == 0 {
+				continue
+			}
+			if !reflect.DeepEqual(exp.Interface(), got.Interface()) {
+				t.Errorf("field %s: expected %#v, got: %#v", field.Name, exp.Interface(), got.Interface())
+			}
+		}
+		if s, exp := parsed.Values().Encode(), req.Values().Encode(); s != exp {
+			t.Errorf("expected %s, got: %s", exp, s)
+		}
+	}
+	for _, s := range []string{"nope=1", "freeleech=maybe", "min_year=x", "page=99999999999999999999"} {
+		q, _ := url.ParseQuery(s)
+		if _, err := ParseSearchValues(q); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+	q, _ := url.ParseQuery("types=UHD+Remux,1080p&types=720p&freeleech=1&order=asc")
+	req, err := ParseSearchValues(q)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s := strings.Join(req.Types, "|"); s != "UHD Remux|1080p|720p" || !req.Freeleech || req.Order != "asc" {
+		t.Errorf("expected types, freeleech and order to be parsed, got: %s %t %s", s, req.Freeleech, req.Order)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		exp   string
+		pos   int
+	}{
+		{
+			`fight club type:"BD Remux" source:Blu-ray group:FraMeSToR year:1995..2005 imdb>=7 freeleech !cam`,
+			"groups=FraMeSToR&max_year=2005&min_imdb=7&min_year=1995&page=1&search=fight+club+%21cam&sources=Blu-ray&types=BD+Remux&freeleech=1",
+			-1,
+		},
+		{
+			`type:"UHD Remux",2160p types:1080p is:pack is:h265 year:1999 bhd:8 imdb:tt0137523 imdb>6 tmdb_votes>=100 "freeleech"`,
+			"h_265=1&imdb_id=tt0137523&max_year=1999&min_bhd=8&min_imdb=6&min_year=1999&page=1&pack=1&search=freeleech&types=UHD+Remux%2C2160p%2C1080p&vote_tmdb=100",
+			-1,
+		},
+		{`Star Wars: "a \"b\"" year<2000 year>=1990 sort:seeders order:asc page:3`, "max_year=1999&min_year=1990&order=asc&page=3&search=Star+Wars%3A+a+%22b%22&sort=seeders", -1},
+		{`2001: a space odyssey year:1968`, "max_year=1968&min_year=1968&page=1&search=2001%3A+a+space+odyssey", -1},
+		{`re:zero type:1080p tpye:remux`, "page=1&search=re%3Azero+tpye%3Aremux&types=1080p", -1},
+		{`Mission: Impossible imdb>7 bhd<8`, "", 27},
+		{`fight "club`, "", 6},
+		{`year:19x5`, "", 5},
+		{`year:..`, "", 5},
+		{`is:shiny`, "", 3},
+		{`type:`, "", 5},
+		{`type:"BD Remux"x`, "", 15},
+		{`bhd<=5`, "", 0},
+		{`sort>=seeders`, "", 0},
+		{`folder:a,b`, "", 7},
+	}
+	for i, test := range tests {
+		req, err := ParseQuery(test.query)
+		if test.pos != -1 {
+			var qe *QueryError
+			if !errors.As(err, &qe) {
+				t.Fatalf("test %d expected *QueryError, got: %v", i, err)
+			}
+			if qe.Pos != test.pos {
+				t.Errorf("test %d expected error position %d, got: %d (%v)", i, test.pos, qe.Pos, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		}
+		exp, _ := url.ParseQuery(test.exp)
+		if s := req.Values().Encode(); s != exp.Encode() {
+			t.Errorf("test %d expected %s, got: %s", i, exp.Encode(), s)
+		}
+		q, err := ParseQuery(req.Query())
+		if err != nil {
+			t.Fatalf("test %d expected no error parsing %q, got: %v", i, req.Query(), err)
+		}
+		if s, exp := q.Values().Encode(), req.Values().Encode(); s != exp {
+			t.Errorf("test %d expected %s to round trip, got: %s (%s)", i, exp, s, req.Query())
+		}
+	}
+}
+
+func FuzzQuery(f *testing.F) {
+	f.Add(`fight club type:"BD Remux" source:Blu-ray group:FraMeSToR year:1995..2005 imdb>=7 freeleech !cam`)
+	f.Add(`"a \"b\"" folder:"x..y" imdb:"7..8" is:notdownloaded page:2 size:123`)
+	f.Add(`Star Wars: year<2000 bhd:8..`)
+	f.Fuzz(func(t *testing.T, query string) {
+		req, err := ParseQuery(query)
+		if err != nil {
+			var qe *QueryError
+			if !errors.As(err, &qe) || qe.Pos < 0 || qe.Pos > len(query) {
+				t.Fatalf("expected *QueryError within query, got: %v", err)
+			}
+			return
+		}
+		q, err := ParseQuery(req.Query())
+		if err != nil {
+			t.Fatalf("expected no error parsing %q, got: %v", req.Query(), err)
+		}
+		if s, exp := q.Values().Encode(), req.Values().Encode(); s != exp {
+			t.Errorf("expected %s, got: %s (%q)", exp, s, req.Query())
+		}
+	})
+}
+
+func FuzzRedact(f *testing.F) {
+	f.Add("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	f.Add("apikeyapikeyapikey", "rsskeyrsskeyrsskey")
+	f.Add("ABCDEFGHIJKLMNOP", "abcdefghijklmnop")
+	f.Fuzz(func(t *testing.T, apiKey, rssKey string) {
+		for _, key := range []string{apiKey, rssKey} {
+			if len(key) < 12 || strings.Trim(key, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+				t.Skip()
+			}
+		}
+		transports := map[string]roundTripper{
+			"transport error": func(*http.Request) (*http.Response, error) {
+				return nil, errors.New("connection refused")
+			},
+			"transport error with url": func(req *http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("proxy rejected %s (%s)", req.URL, req.URL.Path)
+			},
+			"http status": func(req *http.Request) (*http.Response, error) {
+				return response(req, http.StatusInternalServerError, req.URL.String()), nil
+			},
+			"invalid json": func(req *http.Request) (*http.Response, error) {
+				return response(req, http.StatusOK, `{"results":`+req.URL.String()), nil
+			},
+			"canceled": func(req *http.Request) (*http.Response, error) {
+				return nil, req.Context().Err()
+			},
+		}
+		for name, transport := range transports {
+			cl := New(WithApiKey(apiKey), WithRssKey(rssKey, true), WithTransport(transport))
+			ctx, cancel := context.WithCancel(context.Background())
+			if name == "canceled" {
+				cancel()
+			}
+			_, err := cl.Search(ctx, "fight club")
+			checkRedacted(t, name+" (search)", err, true, apiKey, rssKey)
+			_, err = cl.Torrent(ctx, 7531)
+			checkRedacted(t, name+" (torrent)", err, name != "invalid json", apiKey, rssKey)
+			cancel()
+		}
+		downloadURL := "https://beyond-hd.me/torrent/download/Fight.Club.1999.BluRay.1080p.7531." + rssKey
+		if s := Redact(downloadURL); strings.Contains(s, rssKey) {
+			t.Errorf("expected download url to be redacted, got: %s", s)
+		}
+	})
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	throttle := true
+	transport := roundTripper(func(req *http.Request) (*http.Response, error) {
+		if throttle {
+			throttle = false
+			return response(req, http.StatusTooManyRequests, ""), nil
+		}
+		return response(req, http.StatusOK, `{"status_code":1,"page":1,"results":[{"id":1,"name":"Fight Club"}],"total_pages":1,"total_results":1,"success":true,"new_field":1}`), nil
+	})
+	cl := New(WithApiKey("apisecretkey"), WithRssKey("rsssecretkey", true), WithTransport(transport), WithLogger(logger))
+	for i := 0; i < 2; i++ {
+		if _, err := cl.Search(context.Background(), "fight club"); err == nil {
+			t.Fatalf("expected error")
+		}
+	}
+	s := buf.String()
+	t.Logf("log:\n%s", s)
+	for _, exp := range []string{`level=WARN msg=throttled`, `level=WARN msg="schema drift"`, `action=search`, `status=429`} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("expected log to contain %q", exp)
+		}
+	}
+	if strings.Contains(s, "secret") {
+		t.Errorf("expected keys to be redacted")
+	}
+}
+
+func checkRedacted(t *testing.T, name string, err error, expErr bool, keys ...string) {
+	t.Helper()
+	switch {
+	case err == nil && expErr:
+		t.Fatalf("%s: expected error", name)
+	case err == nil:
+		return
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		for _, key := range keys {
+			if strings.Contains(err.Error(), key) {
+				t.Errorf("%s: expected key to be redacted, got: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestRedactedError(t *testing.T) {
+	cl := New(WithApiKey("0123456789abcdef0123456789abcdef"))
+	inner := fmt.Errorf("read 0123456789abcdef0123456789abcdef: %w", context.Canceled)
+	err := cl.redactErr(fmt.Errorf("request: %w", inner))
+	checkRedacted(t, "chain", err, true, cl.ApiKey)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled in chain, got: %v", err)
+	}
+	if exp := "request: read [redacted]: context canceled"; err.Error() != exp {
+		t.Errorf("expected %q, got: %q", exp, err.Error())
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
 func buildClient() *Client {
 	var opts []Option
 	if apiKey := os.Getenv("APIKEY"); apiKey != "" {
@@ -0,0 +1,112 @@
+// Package bhdotel provides OpenTelemetry tracing for bhd clients.
+//
+// The package is part of the bhdapi module, as is bhdprom. OpenTelemetry is
+// only built into programs that import bhdotel.
+package bhdotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/moistari/bhdapi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name.
+const ScopeName = "github.com/moistari/bhdapi/bhdotel"
+
+// Tracer is a bhdapi.Tracer using an OpenTelemetry tracer.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates a tracer using the tracer provider. When provider is nil, the
+// global tracer provider is used.
+func New(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{
+		tracer: provider.Tracer(ScopeName),
+	}
+}
+
+// Option returns a client option to trace client operations.
+//
+// Example:
+//
+//	cl := bhdapi.New(bhdapi.WithCredentialsFrom(bhdapi.LoadCredentials), bhdotel.New(nil).Option())
+func (t *Tracer) Option() bhdapi.Option {
+	return bhdapi.WithTracer(t)
+}
+
+// Start satisfies the bhdapi.Tracer interface.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, bhdapi.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, &Span{span: span}
+}
+
+// Span is a bhdapi.Span wrapping an OpenTelemetry span.
+type Span struct {
+	span trace.Span
+}
+
+// SetAttributes satisfies the bhdapi.Span interface.
+func (s *Span) SetAttributes(attrs ...slog.Attr) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, convert(attr))
+	}
+	s.span.SetAttributes(kvs...)
+}
+
+// End satisfies the bhdapi.Span interface. Errors have already had keys
+// redacted by the client.
+func (s *Span) End(err error) {
+	if err != nil {
+		s.span.SetAttributes(attribute.String("error.type", errorType(err)))
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// convert converts a slog attribute to an OpenTelemetry attribute.
+func convert(attr slog.Attr) attribute.KeyValue {
+	v := attr.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return attribute.String(attr.Key, v.String())
+	case slog.KindInt64:
+		return attribute.Int64(attr.Key, v.Int64())
+	case slog.KindUint64:
+		return attribute.Int64(attr.Key, int64(v.Uint64()))
+	case slog.KindFloat64:
+		return attribute.Float64(attr.Key, v.Float64())
+	case slog.KindBool:
+		return attribute.Bool(attr.Key, v.Bool())
+	}
+	return attribute.String(attr.Key, v.String())
+}
+
+// errorType returns the type of the innermost wrapped error.
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	}
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
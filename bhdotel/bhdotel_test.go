@@ -0,0 +1,108 @@
+package bhdotel
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/bhdtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	srv := bhdtest.NewServer("apikey", "rsskey")
+	defer srv.Close()
+	srv.PageSize = 2
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	cl := bhdapi.New(
+		bhdapi.WithApiKey("apikey"),
+		bhdapi.WithRssKey("rsskey", false),
+		bhdapi.WithTransport(srv.Transport()),
+		New(provider).Option(),
+	)
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	torrents, err := bhdapi.Search("fight club").All(ctx, cl)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if _, err := cl.Torrent(ctx, 1); err == nil {
+		t.Fatalf("expected error")
+	}
+	parent.End()
+	spans := exporter.GetSpans()
+	names := make(map[string]int)
+	for _, span := range spans {
+		names[span.Name]++
+		if span.Name != "parent" && span.Parent.TraceID() != parent.SpanContext().TraceID() {
+			t.Errorf("expected span %s to propagate parent trace id", span.Name)
+		}
+		for _, kv := range span.Attributes {
+			if strings.Contains(kv.Value.Emit(), "rsskey") {
+				t.Errorf("expected span %s attributes to be redacted, got: %v", span.Name, kv)
+			}
+		}
+		for _, ev := range span.Events {
+			for _, kv := range ev.Attributes {
+				if strings.Contains(kv.Value.Emit(), "rsskey") {
+					t.Errorf("expected span %s events to be redacted, got: %v", span.Name, kv)
+				}
+			}
+		}
+	}
+	if n := len(torrents); n != 3 {
+		t.Errorf("expected 3 torrents, got: %d", n)
+	}
+	for name, exp := range map[string]int{
+		"bhdapi.SearchRequest.Next": 2,
+		"bhdapi.SearchRequest.Do":   0,
+		"bhdapi.Client.Torrent":     1,
+	} {
+		if names[name] != exp {
+			t.Errorf("expected %d %s spans, got: %d", exp, name, names[name])
+		}
+	}
+	for _, span := range spans {
+		switch span.Name {
+		case "bhdapi.SearchRequest.Next":
+			for _, kv := range []attribute.KeyValue{
+				attribute.String("bhd.action", "search"),
+				attribute.Int("http.status_code", 200),
+				attribute.Int("bhd.total_results", 3),
+			} {
+				if !hasAttr(span.Attributes, kv) {
+					t.Errorf("expected %v attribute, got: %v", kv, span.Attributes)
+				}
+			}
+			if !hasKey(span.Attributes, "bhd.page") || !hasKey(span.Attributes, "bhd.results") {
+				t.Errorf("expected page and results attributes, got: %v", span.Attributes)
+			}
+		case "bhdapi.Client.Torrent":
+			if span.Status.Code != codes.Error || !hasAttr(span.Attributes, attribute.Int("http.status_code", 404)) {
+				t.Errorf("expected error status, got: %v %v", span.Status, span.Attributes)
+			}
+		}
+	}
+}
+
+func hasAttr(attrs []attribute.KeyValue, exp attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if kv.Key == exp.Key && kv.Value.Emit() == exp.Value.Emit() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasKey(attrs []attribute.KeyValue, key attribute.Key) bool {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return true
+		}
+	}
+	return false
+}
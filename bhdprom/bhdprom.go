@@ -0,0 +1,151 @@
+// Package bhdprom provides prometheus metrics for bhd clients.
+//
+// The bhd client does not retry requests or cache responses, so there are no
+// retry or cache hit metrics: throttled requests are counted by
+// bhdapi_throttled_total.
+//
+// The package is part of the bhdapi module, as is bhdotel. Prometheus is only
+// built into programs that import bhdprom.
+package bhdprom
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/moistari/bhdapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are bhd client metrics.
+type Metrics struct {
+	requests  *prometheus.CounterVec
+	latency   *prometheus.HistogramVec
+	throttled *prometheus.CounterVec
+	bytes     prometheus.Counter
+}
+
+// New creates bhd client metrics, registering them on the registerer.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "bhdapi",
+			Name:      "requests_total",
+			Help:      "Total number of bhd requests by action and outcome.",
+		}, []string{"action", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "bhdapi",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of bhd requests by action.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+		}, []string{"action"}),
+		throttled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "bhdapi",
+			Name:      "throttled_total",
+			Help:      "Total number of throttled bhd requests by action.",
+		}, []string{"action"}),
+		bytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "bhdapi",
+			Name:      "torrent_bytes_total",
+			Help:      "Total number of downloaded torrent bytes.",
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.latency, m.throttled, m.bytes} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Option returns a client option that instruments the client's http
+// transport. Must be passed after any bhdapi.WithTransport option.
+//
+// Example:
+//
+//	m, err := bhdprom.New(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		/* ... */
+//	}
+//	cl := bhdapi.New(bhdapi.WithCredentialsFrom(bhdapi.LoadCredentials), m.Option())
+func (m *Metrics) Option() bhdapi.Option {
+	return func(cl *bhdapi.Client) {
+		cl.Transport = m.RoundTripper(cl.Transport)
+	}
+}
+
+// RoundTripper wraps the http transport, recording metrics for requests to
+// the bhd api. When next is nil, http.DefaultTransport is used.
+func (m *Metrics) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		action := Action(req)
+		start := time.Now()
+		res, err := next.RoundTrip(req)
+		m.latency.WithLabelValues(action).Observe(time.Since(start).Seconds())
+		switch {
+		case err != nil:
+			m.requests.WithLabelValues(action, "error").Inc()
+			return nil, err
+		case res.StatusCode == http.StatusTooManyRequests:
+			m.requests.WithLabelValues(action, "throttled").Inc()
+			m.throttled.WithLabelValues(action).Inc()
+		case res.StatusCode != http.StatusOK:
+			m.requests.WithLabelValues(action, "http_error").Inc()
+		default:
+			m.requests.WithLabelValues(action, "success").Inc()
+			if action == "torrent" {
+				res.Body = &countReader{ReadCloser: res.Body, c: m.bytes}
+			}
+		}
+		return res, nil
+	})
+}
+
+// Action returns the bhd action for the request. Api requests return the
+// action in the request body, torrent downloads return "torrent", and all
+// other requests return "other".
+func Action(req *http.Request) string {
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/torrent/download/"):
+		return "torrent"
+	case strings.HasPrefix(req.URL.Path, "/api/") && req.GetBody != nil:
+		body, err := req.GetBody()
+		if err != nil {
+			break
+		}
+		defer body.Close()
+		var v struct {
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(body).Decode(&v); err == nil && v.Action != "" {
+			return v.Action
+		}
+	}
+	return "other"
+}
+
+// countReader counts the bytes read.
+type countReader struct {
+	io.ReadCloser
+	c prometheus.Counter
+}
+
+// Read satisfies the io.Reader interface.
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.c.Add(float64(n))
+	return n, err
+}
+
+// roundTripper wraps a func as a http.RoundTripper.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
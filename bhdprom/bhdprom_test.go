@@ -0,0 +1,62 @@
+package bhdprom
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/bhdtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := bhdtest.NewServer("apikey", "rsskey")
+	defer srv.Close()
+	reg := prometheus.NewPedanticRegistry()
+	m, err := New(reg)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	cl := bhdapi.New(
+		bhdapi.WithApiKey("apikey"),
+		bhdapi.WithRssKey("rsskey", false),
+		bhdapi.WithTransport(srv.Transport()),
+		m.Option(),
+	)
+	ctx := context.Background()
+	res, err := cl.Search(ctx, "fight club")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n, exp := len(res.Results), 3; n != exp {
+		t.Fatalf("expected %d results, got: %d", exp, n)
+	}
+	buf, err := cl.Torrent(ctx, res.Results[0].ID)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	srv.Status = http.StatusTooManyRequests
+	if _, err := cl.Search(ctx, "fight club"); err == nil {
+		t.Fatalf("expected error")
+	}
+	tests := []struct {
+		c   prometheus.Collector
+		exp float64
+	}{
+		{m.requests.WithLabelValues("search", "success"), 1},
+		{m.requests.WithLabelValues("search", "throttled"), 1},
+		{m.requests.WithLabelValues("torrent", "success"), 1},
+		{m.throttled.WithLabelValues("search"), 1},
+		{m.bytes, float64(len(buf))},
+	}
+	for i, test := range tests {
+		if v := testutil.ToFloat64(test.c); v != test.exp {
+			t.Errorf("test %d expected %f, got: %f", i, test.exp, v)
+		}
+	}
+	if n, err := testutil.GatherAndCount(reg, "bhdapi_request_duration_seconds"); err != nil || n != 2 {
+		t.Errorf("expected 2 latency series, got: %d (%v)", n, err)
+	}
+}
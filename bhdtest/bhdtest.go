@@ -0,0 +1,455 @@
+// Package bhdtest provides a fake bhd server for testing.
+package bhdtest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Torrent is a torrent fixture, encoded as returned by the bhd api.
+type Torrent map[string]interface{}
+
+// ID returns the torrent's id.
+func (t Torrent) ID() int {
+	switch v := t["id"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// Peer states.
+const (
+	// PeerSeeding is a completed torrent the user is seeding.
+	PeerSeeding = "seeding"
+	// PeerLeeching is a torrent the user is downloading.
+	PeerLeeching = "leeching"
+	// PeerCompleted is a completed torrent the user is not seeding.
+	PeerCompleted = "completed"
+	// PeerIncomplete is a partially downloaded torrent the user is not
+	// downloading.
+	PeerIncomplete = "incomplete"
+)
+
+// peers are the peer states matched by the peer search params.
+var peers = map[string][]string{
+	"seeding":       {PeerSeeding},
+	"leeching":      {PeerLeeching},
+	"completed":     {PeerSeeding, PeerCompleted},
+	"incomplete":    {PeerLeeching, PeerIncomplete},
+	"notdownloaded": {""},
+}
+
+// Server is a fake bhd server.
+type Server struct {
+	*httptest.Server
+	// ApiKey is the api key accepted by the server.
+	ApiKey string
+	// RssKey is the rss key accepted by the server.
+	RssKey string
+	// Passkey is the passkey used in the announce url of served torrents.
+	Passkey string
+	// PageSize is the number of results per page.
+	PageSize int
+	// Status, when non-zero, is the http status returned for all requests.
+	Status int
+	// Peers are the user's peer states by torrent id, matched by the seeding,
+	// leeching, completed, incomplete and notdownloaded search params. See
+	// the Peer constants.
+	Peers map[int]string
+
+	mu       sync.Mutex
+	torrents []Torrent
+	files    map[int][]byte
+	requests map[string]int
+}
+
+// NewServer creates and starts a fake bhd server serving the torrents. When
+// no torrents are provided, Fixtures is used. The info_hash and
+// download_url of each torrent is set from the generated metainfo.
+func NewServer(apiKey, rssKey string, torrents ...Torrent) *Server {
+	if len(torrents) == 0 {
+		torrents = Fixtures()
+	}
+	s := &Server{
+		ApiKey:   apiKey,
+		RssKey:   rssKey,
+		Passkey:  "0123456789abcdef0123456789abcdef",
+		PageSize: 100,
+		Peers:    make(map[int]string),
+		files:    make(map[int][]byte),
+		requests: make(map[string]int),
+	}
+	for _, t := range torrents {
+		s.Add(t)
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Add adds a torrent to the server, generating its metainfo.
+func (s *Server) Add(t Torrent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := t.ID()
+	name, _ := t["folder_name"].(string)
+	if name == "" {
+		name, _ = t["name"].(string)
+	}
+	size, _ := t["size"].(int64)
+	buf, infoHash := Metainfo("https://tracker.beyond-hd.me:2053/announce/"+s.Passkey, name, size)
+	t["info_hash"] = infoHash
+	t["url"] = fmt.Sprintf("https://beyond-hd.me/torrents/%s.%d", dotted(name), id)
+	t["download_url"] = fmt.Sprintf("https://beyond-hd.me/torrent/download/%s.%d.%s", dotted(name), id, s.RssKey)
+	s.torrents = append(s.torrents, t)
+	s.files[id] = buf
+}
+
+// Torrents returns the server's torrents.
+func (s *Server) Torrents() []Torrent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Torrent(nil), s.torrents...)
+}
+
+// File returns the metainfo for the torrent id.
+func (s *Server) File(id int) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files[id]
+}
+
+// Requests returns the number of requests handled for the action. Torrent
+// downloads are counted under the "download" action.
+func (s *Server) Requests(action string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[action]
+}
+
+// Transport returns a http transport that sends all requests to the server.
+func (s *Server) Transport() http.RoundTripper {
+	u, _ := url.Parse(s.URL)
+	transport := s.Client().Transport
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme, req.URL.Host, req.Host = u.Scheme, u.Host, u.Host
+		return transport.RoundTrip(req)
+	})
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case s.Status != 0:
+		http.Error(w, http.StatusText(s.Status), s.Status)
+	case req.Method == "POST" && strings.HasPrefix(req.URL.Path, "/api/torrents/"):
+		s.serveApi(w, req, strings.TrimPrefix(req.URL.Path, "/api/torrents/"))
+	case req.Method == "GET" && strings.HasPrefix(req.URL.Path, "/torrent/download/"):
+		s.serveDownload(w, strings.TrimPrefix(req.URL.Path, "/torrent/download/"))
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveApi serves an api request.
+func (s *Server) serveApi(w http.ResponseWriter, req *http.Request, key string) {
+	params := make(map[string]interface{})
+	if err := json.NewDecoder(req.Body).Decode(&params); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action, _ := params["action"].(string)
+	s.mu.Lock()
+	s.requests[action]++
+	s.mu.Unlock()
+	switch {
+	case key != s.ApiKey:
+		writeJSON(w, map[string]interface{}{
+			"status_code":    0,
+			"success":        false,
+			"status_message": "Invalid API key.",
+		})
+	case action == "search":
+		s.serveSearch(w, params)
+	default:
+		writeJSON(w, map[string]interface{}{
+			"status_code":    0,
+			"success":        false,
+			"status_message": "Invalid action.",
+		})
+	}
+}
+
+// serveSearch serves a search request.
+func (s *Server) serveSearch(w http.ResponseWriter, params map[string]interface{}) {
+	var results []Torrent
+	s.mu.Lock()
+	states := make(map[int]string, len(s.Peers))
+	for id, state := range s.Peers {
+		states[id] = state
+	}
+	s.mu.Unlock()
+	for _, t := range s.Torrents() {
+		if match(t, params, states[t.ID()]) {
+			results = append(results, t)
+		}
+	}
+	sortTorrents(results, str(params["sort"]), str(params["order"]))
+	page, pages := 1, (len(results)+s.PageSize-1)/s.PageSize
+	if p, ok := params["page"].(float64); ok && p > 1 {
+		page = int(p)
+	}
+	start, end := min((page-1)*s.PageSize, len(results)), min(page*s.PageSize, len(results))
+	res := map[string]interface{}{
+		"status_code":   1,
+		"page":          page,
+		"total_pages":   pages,
+		"total_results": len(results),
+		"success":       true,
+	}
+	if start < end {
+		res["results"] = results[start:end]
+	}
+	writeJSON(w, res)
+}
+
+// serveDownload serves a torrent download.
+func (s *Server) serveDownload(w http.ResponseWriter, name string) {
+	m := downloadRE.FindStringSubmatch(name)
+	s.mu.Lock()
+	s.requests["download"]++
+	s.mu.Unlock()
+	if m == nil || m[2] != s.RssKey {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.Atoi(m[1])
+	buf := s.File(id)
+	if buf == nil {
+		http.NotFound(w, nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-bittorrent")
+	_, _ = w.Write(buf)
+}
+
+// downloadRE matches torrent download names.
+var downloadRE = regexp.MustCompile(`\.([0-9]+)\.([^./]+)$`)
+
+// flags are the search params matched against the torrent's bool fields.
+var flags = map[string]string{
+	"freeleech": "freeleech",
+	"limited":   "limited",
+	"promo25":   "promo25",
+	"promo50":   "promo50",
+	"promo75":   "promo75",
+	"refund":    "refund",
+	"rescue":    "rescue",
+	"rewind":    "rewind",
+	"pack":      "tv_pack",
+}
+
+// lists are the comma separated search params matched against the torrent's
+// fields.
+var lists = map[string]string{
+	"categories": "category",
+	"types":      "type",
+}
+
+// match returns true when the torrent, with the user's peer state, matches
+// the search params.
+func match(t Torrent, params map[string]interface{}, state string) bool {
+	for k, v := range params {
+		switch k {
+		case "search":
+			name := strings.ToLower(str(t["name"]))
+			for _, word := range strings.Fields(strings.ToLower(str(v))) {
+				if neg := strings.HasPrefix(word, "!"); neg && strings.Contains(name, word[1:]) || !neg && !strings.Contains(name, word) {
+					return false
+				}
+			}
+		case "info_hash", "folder_name", "imdb_id", "tmdb_id", "uploaded_by":
+			if !strings.EqualFold(str(t[k]), str(v)) {
+				return false
+			}
+		case "size":
+			if f, _ := v.(float64); int64(f) != t["size"] {
+				return false
+			}
+		default:
+			if field, ok := lists[k]; ok && !contains(strings.Split(str(v), ","), str(t[field])) {
+				return false
+			}
+			if field, ok := flags[k]; ok && v == float64(1) && t[field] != 1 {
+				return false
+			}
+			if states, ok := peers[k]; ok && v == float64(1) && !contains(states, state) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sortTorrents sorts the torrents by the field and order.
+func sortTorrents(torrents []Torrent, field, order string) {
+	if field == "" {
+		field = "id"
+	}
+	sort.SliceStable(torrents, func(i, j int) bool {
+		if order != "asc" {
+			i, j = j, i
+		}
+		a, b := torrents[i][field], torrents[j][field]
+		if x, ok := a.(string); ok {
+			return x < str(b)
+		}
+		return num(a) < num(b)
+	})
+}
+
+// Metainfo generates a bencoded v1 metainfo for a single file torrent with
+// the name and size, returning it and its hex encoded info hash.
+func Metainfo(announce, name string, size int64) ([]byte, string) {
+	pieceLength := int64(1 << 24)
+	n := (size + pieceLength - 1) / pieceLength
+	pieces := make([]byte, 0, n*sha1.Size)
+	for i := int64(0); i < n; i++ {
+		h := sha1.Sum([]byte(name + strconv.FormatInt(i, 10)))
+		pieces = append(pieces, h[:]...)
+	}
+	info := new(bytes.Buffer)
+	fmt.Fprintf(info, "d6:lengthi%de4:name%d:%s12:piece lengthi%de6:pieces%d:", size, len(name), name, pieceLength, len(pieces))
+	info.Write(pieces)
+	info.WriteString("7:privatei1e6:source3:BHDe")
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "d8:announce%d:%s4:info", len(announce), announce)
+	buf.Write(info.Bytes())
+	buf.WriteString("e")
+	h := sha1.Sum(info.Bytes())
+	return buf.Bytes(), hex.EncodeToString(h[:])
+}
+
+// Fixtures returns a set of torrent fixtures.
+func Fixtures() []Torrent {
+	return []Torrent{
+		{
+			"id": 7531, "name": "Fight Club 1999 BluRay 1080p DTS-HD MA 5.1 AVC REMUX-FraMeSToR",
+			"folder_name": "Fight.Club.1999.BluRay.1080p.DTS-HD.MA.5.1.AVC.REMUX-FraMeSToR", "size": int64(38702381297),
+			"uploaded_by": "Anonymous", "category": "Movies", "type": "BD Remux", "seeders": 112, "leechers": 0, "times_completed": 1042,
+			"imdb_id": "tt0137523", "tmdb_id": "550", "bhd_rating": 8.6, "tmdb_rating": 8.4, "imdb_rating": 8.8,
+			"tv_pack": 0, "promo25": 0, "promo50": 0, "promo75": 0, "freeleech": 0, "rewind": 0, "refund": 0, "limited": 0, "rescue": 0,
+			"dv": 0, "hdr10": 0, "hdr10+": 0, "hlg": 0, "commentary": 1, "internal": 1,
+			"bumped_at": "2020-01-12 18:05:32", "created_at": "2019-06-02 11:15:42",
+		},
+		{
+			"id": 101233, "name": "Fight Club 1999 UHD BluRay 2160p TrueHD Atmos 7.1 DV HEVC REMUX-FraMeSToR",
+			"folder_name": "Fight.Club.1999.UHD.BluRay.2160p.TrueHD.Atmos.7.1.DV.HEVC.REMUX-FraMeSToR", "size": int64(71263782400),
+			"uploaded_by": "Anonymous", "category": "Movies", "type": "UHD Remux", "seeders": 241, "leechers": 3, "times_completed": 1877,
+			"imdb_id": "tt0137523", "tmdb_id": "550", "bhd_rating": 9.1, "tmdb_rating": 8.4, "imdb_rating": 8.8,
+			"tv_pack": 0, "promo25": 0, "promo50": 0, "promo75": 0, "freeleech": 1, "rewind": 0, "refund": 0, "limited": 0, "rescue": 0,
+			"dv": 1, "hdr10": 1, "hdr10+": 0, "hlg": 0, "commentary": 1, "internal": 1,
+			"bumped_at": "2022-05-30 09:12:01", "created_at": "2021-11-20 21:45:10",
+		},
+		{
+			"id": 150012, "name": "Fight Club 1999 1080p BluRay DD+ 5.1 x264-BHDStudio",
+			"folder_name": "Fight.Club.1999.1080p.BluRay.DD+.5.1.x264-BHDStudio", "size": int64(12884901888),
+			"uploaded_by": "BHDStudio", "category": "Movies", "type": "1080p", "seeders": 87, "leechers": 1, "times_completed": 640,
+			"imdb_id": "tt0137523", "tmdb_id": "550", "bhd_rating": 8.0, "tmdb_rating": 8.4, "imdb_rating": 8.8,
+			"tv_pack": 0, "promo25": 0, "promo50": 1, "promo75": 0, "freeleech": 0, "rewind": 0, "refund": 0, "limited": 0, "rescue": 0,
+			"dv": 0, "hdr10": 0, "hdr10+": 0, "hlg": 0, "commentary": 0, "internal": 1,
+			"bumped_at": "2022-08-14 14:20:55", "created_at": "2022-08-14 14:20:55",
+		},
+		{
+			"id": 162200, "name": "The Matrix 1999 UHD BluRay 2160p DTS-HD MA 5.1 HDR10+ HEVC REMUX-FraMeSToR",
+			"folder_name": "The.Matrix.1999.UHD.BluRay.2160p.DTS-HD.MA.5.1.HDR10+.HEVC.REMUX-FraMeSToR", "size": int64(68719476736),
+			"uploaded_by": "Anonymous", "category": "Movies", "type": "UHD Remux", "seeders": 302, "leechers": 5, "times_completed": 2210,
+			"imdb_id": "tt0133093", "tmdb_id": "603", "bhd_rating": 9.3, "tmdb_rating": 8.2, "imdb_rating": 8.7,
+			"tv_pack": 0, "promo25": 0, "promo50": 0, "promo75": 0, "freeleech": 1, "rewind": 0, "refund": 0, "limited": 0, "rescue": 0,
+			"dv": 0, "hdr10": 1, "hdr10+": 1, "hlg": 0, "commentary": 0, "internal": 1,
+			"bumped_at": "2023-01-03 08:00:00", "created_at": "2023-01-02 22:31:09",
+		},
+		{
+			"id": 170555, "name": "The Matrix 1999 720p WEB-DL DD 5.1 H.264-NTb",
+			"folder_name": "The.Matrix.1999.720p.WEB-DL.DD.5.1.H.264-NTb", "size": int64(4294967296),
+			"uploaded_by": "uploader", "category": "Movies", "type": "720p", "seeders": 4, "leechers": 0, "times_completed": 91,
+			"imdb_id": "tt0133093", "tmdb_id": "603", "bhd_rating": 6.1, "tmdb_rating": 8.2, "imdb_rating": 8.7,
+			"tv_pack": 0, "promo25": 1, "promo50": 0, "promo75": 0, "freeleech": 0, "rewind": 0, "refund": 0, "limited": 0, "rescue": 0,
+			"dv": 0, "hdr10": 0, "hdr10+": 0, "hlg": 0, "commentary": 0, "internal": 0,
+			"bumped_at": "2023-03-10 12:45:00", "created_at": "2023-03-10 12:45:00",
+		},
+		{
+			"id": 180777, "name": "Severance S01 2160p ATVP WEB-DL DDP 5.1 DV HDR H.265-NTb",
+			"folder_name": "Severance.S01.2160p.ATVP.WEB-DL.DDP.5.1.DV.HDR.H.265-NTb", "size": int64(53687091200),
+			"uploaded_by": "uploader", "category": "TV", "type": "2160p", "seeders": 58, "leechers": 2, "times_completed": 410,
+			"imdb_id": "tt11280740", "tmdb_id": "95396", "bhd_rating": 8.9, "tmdb_rating": 8.4, "imdb_rating": 8.7,
+			"tv_pack": 1, "promo25": 0, "promo50": 0, "promo75": 0, "freeleech": 0, "rewind": 0, "refund": 0, "limited": 0, "rescue": 0,
+			"dv": 1, "hdr10": 1, "hdr10+": 0, "hlg": 0, "commentary": 0, "internal": 0,
+			"bumped_at": "2023-06-01 18:30:00", "created_at": "2022-04-08 07:12:33",
+		},
+	}
+}
+
+// roundTripper wraps a func as a http.RoundTripper.
+type roundTripper func(*http.Request) (*http.Response, error)
+
+// RoundTrip satisfies the http.RoundTripper interface.
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// writeJSON writes v as json.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// dotted replaces spaces in s with dots.
+func dotted(s string) string {
+	return strings.ReplaceAll(s, " ", ".")
+}
+
+// contains returns true when v is in s.
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if strings.EqualFold(strings.TrimSpace(x), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// str returns v as a string.
+func str(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// num returns v as a float64.
+func num(v interface{}) float64 {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case float64:
+		return x
+	}
+	return 0
+}
@@ -7,9 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
+
+	"github.com/moistari/bhdapi/metainfo"
 )
 
 // Client is a BHD client.
@@ -19,6 +23,9 @@ type Client struct {
 	ApiKey    string
 	RssKey    string
 	Transport http.RoundTripper
+	Logger    *slog.Logger
+	Tracer    Tracer
+	err       error
 }
 
 // New creates a new BHD client.
@@ -35,35 +42,39 @@ func New(opts ...Option) *Client {
 	return cl
 }
 
-// Do executes the action and params, decoding the result.
+// Do executes the action and params, decoding the result. Params must be a
+// pointer to a struct, and its fields are encoded using their json tags. The
+// client's keys are redacted from any returned error.
+//
+// See DoParams for executing Params, such as a *SearchRequest.
 func (cl *Client) Do(ctx context.Context, action string, params, result interface{}) error {
+	m, err := encode(params)
+	if err != nil {
+		return err
+	}
+	return cl.DoParams(ctx, rawParams{action: action, m: m}, result)
+}
+
+// DoParams executes the params' action, decoding the result. The client's
+// keys are redacted from any returned error.
+func (cl *Client) DoParams(ctx context.Context, params Params, result interface{}) error {
+	return cl.redactErr(cl.do(ctx, params, result))
+}
+
+// do executes the params' action, decoding the result.
+func (cl *Client) do(ctx context.Context, params Params, result interface{}) error {
+	if cl.err != nil {
+		return cl.err
+	}
 	if cl.ApiKey == "" {
 		return errors.New("must supply api key")
 	}
-	m := map[string]interface{}{
-		"action": action,
-	}
+	action := params.Action()
+	m := params.Encode()
+	m["action"] = action
 	if cl.AddRssKey && cl.RssKey != "" {
 		m["rsskey"] = cl.RssKey
 	}
-	v := reflect.ValueOf(params).Elem()
-	if v.Kind() != reflect.Struct {
-		return errors.New("must past a pointer to a struct")
-	}
-	typ := v.Type()
-	for i := 0; i < v.NumField(); i++ {
-		tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]
-		if tag == "-" || tag == "" {
-			continue
-		}
-		vv, ok, err := val(v.Field(i).Interface())
-		if err != nil {
-			return fmt.Errorf("invalid field %d: %w", i, err)
-		}
-		if ok {
-			m[tag] = vv
-		}
-	}
 	buf, err := json.Marshal(m)
 	if err != nil {
 		return err
@@ -73,17 +84,30 @@ func (cl *Client) Do(ctx context.Context, action string, params, result interfac
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	start := time.Now()
 	res, err := cl.cl.Do(req.WithContext(ctx))
 	if err != nil {
+		cl.log(ctx, slog.LevelDebug, "request failed", "action", action, "params", redactParams(m), "latency", time.Since(start), "error", cl.Redact(err.Error()))
 		return err
 	}
 	defer res.Body.Close()
+	spanAttrs(ctx, slog.Int("http.status_code", res.StatusCode))
+	cl.log(ctx, slog.LevelDebug, "request", "action", action, "params", redactParams(m), "status", res.StatusCode, "latency", time.Since(start))
 	if res.StatusCode != http.StatusOK {
+		cl.logStatus(ctx, res, "action", action)
 		return fmt.Errorf("invalid http status %d", res.StatusCode)
 	}
 	dec := json.NewDecoder(res.Body)
-	dec.DisallowUnknownFields()
-	return dec.Decode(result)
+	// only the documented search response is decoded strictly, so that
+	// undocumented responses with unknown fields still decode
+	if _, ok := result.(*SearchResponse); ok {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(result); err != nil {
+		cl.logDecode(ctx, err, "action", action)
+		return err
+	}
+	return nil
 }
 
 // Search searches for a query.
@@ -91,8 +115,76 @@ func (cl *Client) Search(ctx context.Context, query ...string) (*SearchResponse,
 	return Search(query...).Do(ctx, cl)
 }
 
-// Torrent retrieves a torrent for the id.
+// Torrent retrieves a torrent for the id. The client's keys are redacted from
+// any returned error.
 func (cl *Client) Torrent(ctx context.Context, id int) ([]byte, error) {
+	ctx, span := cl.start(ctx, "bhdapi.Client.Torrent", slog.String("bhd.action", "torrent"), slog.Int("bhd.torrent_id", id))
+	buf, err := cl.torrent(ctx, id)
+	err = cl.redactErr(err)
+	span.SetAttributes(slog.Int("bhd.bytes", len(buf)))
+	span.End(err)
+	return buf, err
+}
+
+// AnnounceURL retrieves the torrent for the id, returning the personal
+// announce url from its metainfo. The announce url contains the user's
+// passkey, and is the same for all torrents.
+func (cl *Client) AnnounceURL(ctx context.Context, id int) (string, error) {
+	buf, err := cl.Torrent(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	m, err := metainfo.Parse(buf)
+	if err != nil {
+		return "", fmt.Errorf("torrent %d: %w", id, err)
+	}
+	if m.Announce() == "" {
+		return "", fmt.Errorf("torrent %d: missing announce url", id)
+	}
+	return m.Announce(), nil
+}
+
+// LookupResult is the result of a torrent lookup.
+type LookupResult struct {
+	// InfoHash is the looked up info hash.
+	InfoHash string
+	// Exact is true when the results matched the info hash, and false when
+	// the results matched the folder name and size.
+	Exact bool
+	// Results are the matching torrents.
+	Results []Torrent
+}
+
+// Lookup searches for torrents matching the metainfo, such as one created
+// from local content with metainfo.Create. Torrents are searched for by info
+// hash, falling back to the metainfo's name as the folder name and its size,
+// as the same content hashed with a different piece length has a different
+// info hash.
+func (cl *Client) Lookup(ctx context.Context, m *metainfo.Metainfo) (*LookupResult, error) {
+	res := &LookupResult{
+		InfoHash: m.InfoHash(),
+	}
+	for i, req := range []*SearchRequest{
+		Search().WithInfoHash(m.InfoHash()),
+		Search().WithFolderName(m.Name()).WithSize(m.Size()),
+	} {
+		torrents, err := req.All(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		if len(torrents) != 0 {
+			res.Exact, res.Results = i == 0, torrents
+			break
+		}
+	}
+	return res, nil
+}
+
+// torrent retrieves a torrent for the id.
+func (cl *Client) torrent(ctx context.Context, id int) ([]byte, error) {
+	if cl.err != nil {
+		return nil, cl.err
+	}
 	if cl.RssKey == "" {
 		return nil, errors.New("must supply rss key")
 	}
@@ -100,15 +192,22 @@ func (cl *Client) Torrent(ctx context.Context, id int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	start := time.Now()
 	res, err := cl.cl.Do(req.WithContext(ctx))
 	if err != nil {
+		cl.log(ctx, slog.LevelDebug, "torrent failed", "id", id, "latency", time.Since(start), "error", cl.Redact(err.Error()))
 		return nil, err
 	}
 	defer res.Body.Close()
+	spanAttrs(ctx, slog.Int("http.status_code", res.StatusCode))
 	if res.StatusCode != http.StatusOK {
+		cl.log(ctx, slog.LevelDebug, "torrent", "id", id, "status", res.StatusCode, "latency", time.Since(start))
+		cl.logStatus(ctx, res, "id", id)
 		return nil, fmt.Errorf("invalid http status %d", res.StatusCode)
 	}
-	return io.ReadAll(res.Body)
+	buf, err := io.ReadAll(res.Body)
+	cl.log(ctx, slog.LevelDebug, "torrent", "id", id, "status", res.StatusCode, "latency", time.Since(start), "bytes", len(buf))
+	return buf, err
 }
 
 // Option is a BHD client option.
@@ -128,6 +227,40 @@ func WithRssKey(rssKey string, addRssKey bool) Option {
 	}
 }
 
+// WithCredentialsFrom is a client option to set the api and rss keys from
+// the loaded credentials. Any error loading the credentials is returned by
+// the client's methods.
+//
+// Example:
+//
+//	cl := bhdapi.New(bhdapi.WithCredentialsFrom(bhdapi.LoadCredentials))
+func WithCredentialsFrom(f CredentialsFunc) Option {
+	return func(cl *Client) {
+		creds, err := f(context.Background())
+		if err != nil {
+			cl.err = fmt.Errorf("unable to load credentials: %w", err)
+			return
+		}
+		if creds.ApiKey != "" {
+			cl.ApiKey = creds.ApiKey
+		}
+		if creds.RssKey != "" {
+			cl.RssKey = creds.RssKey
+		}
+	}
+}
+
+// WithLogger is a client option to set the logger used. Requests are logged
+// at debug level, and throttling and schema changes are logged at warn level.
+// Keys are redacted from all logged values. The client does not retry
+// requests, so there are no retry attempts to log: throttled requests are
+// logged with the response's Retry-After value, for the caller to retry.
+func WithLogger(logger *slog.Logger) Option {
+	return func(cl *Client) {
+		cl.Logger = logger
+	}
+}
+
 // WithTransport is a client option to set the http transport used.
 func WithTransport(transport http.RoundTripper) Option {
 	return func(cl *Client) {
@@ -135,6 +268,47 @@ func WithTransport(transport http.RoundTripper) Option {
 	}
 }
 
+// rawParams are the action and encoded params passed to Do.
+type rawParams struct {
+	action string
+	m      map[string]interface{}
+}
+
+// Action satisfies the Params interface.
+func (p rawParams) Action() string {
+	return p.action
+}
+
+// Encode satisfies the Params interface.
+func (p rawParams) Encode() map[string]interface{} {
+	return p.m
+}
+
+// encode encodes the fields of a pointer to a struct using their json tags.
+func encode(params interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("must pass a pointer to a struct")
+	}
+	v = v.Elem()
+	typ := v.Type()
+	m := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]
+		if tag == "-" || tag == "" || !typ.Field(i).IsExported() {
+			continue
+		}
+		vv, ok, err := val(v.Field(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %d: %w", i, err)
+		}
+		if ok {
+			m[tag] = vv
+		}
+	}
+	return m, nil
+}
+
 // val encodes v as necessary, returning whether or not it is the zero value.
 func val(v interface{}) (interface{}, bool, error) {
 	switch x := v.(type) {
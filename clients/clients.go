@@ -0,0 +1,58 @@
+// Package clients defines the interface shared by the torrent client
+// integrations.
+package clients
+
+import (
+	"context"
+
+	"github.com/moistari/bhdapi/metainfo"
+)
+
+// TorrentClient is a torrent client.
+type TorrentClient interface {
+	// Add adds the torrent metainfo, returning its info hash. When a torrent
+	// with the same info hash already exists, the torrent is not added and
+	// exists is true.
+	Add(ctx context.Context, metainfo []byte, opts AddOptions) (infoHash string, exists bool, err error)
+	// Exists returns true when a torrent with the info hash exists.
+	Exists(ctx context.Context, infoHash string) (bool, error)
+	// List returns the client's torrents.
+	List(ctx context.Context) ([]Torrent, error)
+}
+
+// Torrent is a torrent in a torrent client.
+type Torrent struct {
+	// InfoHash is the lower case hex encoded info hash.
+	InfoHash string `json:"info_hash"`
+	// Name is the torrent's name.
+	Name string `json:"name"`
+	// Complete is true when the torrent's data has been fully downloaded.
+	Complete bool `json:"complete"`
+	// Active is true when the torrent is started (seeding, downloading or
+	// checking), and false when it is paused, stopped or errored.
+	Active bool `json:"active"`
+}
+
+// AddOptions are the options for adding a torrent. Clients ignore options
+// they do not support.
+type AddOptions struct {
+	// Category is the torrent's category.
+	Category string
+	// Tags are the torrent's tags or labels.
+	Tags []string
+	// SavePath is the torrent's download directory.
+	SavePath string
+	// Paused adds the torrent paused (stopped).
+	Paused bool
+	// SkipChecking skips hash checking of existing data.
+	SkipChecking bool
+}
+
+// InfoHash returns the hex encoded v1 info hash of the torrent metainfo.
+func InfoHash(buf []byte) (string, error) {
+	m, err := metainfo.Parse(buf)
+	if err != nil {
+		return "", err
+	}
+	return m.InfoHash(), nil
+}
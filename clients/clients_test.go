@@ -0,0 +1,112 @@
+package clients
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/bhdtest"
+)
+
+func TestInfoHash(t *testing.T) {
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999", 38702381297)
+	infoHash, err := InfoHash(buf)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp:
+		t.Errorf("expected %s, got: %s", exp, infoHash)
+	}
+	for _, s := range []string{
+		"",
+		"le",
+		"d8:announce3:urle",
+		"d4:infod4:name",
+		"d4:infoi1",
+		"d99:info",
+		"d4:infox",
+	} {
+		if _, err := InfoHash([]byte(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	srv.PageSize = 1
+	srv.Peers = map[int]string{
+		7531:   bhdtest.PeerSeeding,
+		101233: bhdtest.PeerSeeding,
+		150012: bhdtest.PeerLeeching,
+		162200: bhdtest.PeerCompleted,
+		170555: bhdtest.PeerCompleted,
+		180777: bhdtest.PeerCompleted,
+	}
+	srv.Add(bhdtest.Torrent{"id": 190000, "name": "Other Video 2024 1080p", "folder_name": "Other.Video.2024.1080p", "size": int64(8589934592)})
+	hashes := make(map[int]string)
+	for _, torrent := range srv.Torrents() {
+		hashes[torrent.ID()] = torrent["info_hash"].(string)
+	}
+	tc := &fakeClient{torrents: []Torrent{
+		{InfoHash: hashes[7531], Complete: true, Active: true},
+		{InfoHash: strings.ToUpper(hashes[150012]), Active: true},
+		{InfoHash: hashes[162200], Complete: true, Active: true},
+		{InfoHash: hashes[170555], Complete: true},
+		{InfoHash: hashes[190000], Complete: true, Active: true},
+		{InfoHash: strings.Repeat("0", 40), Complete: true, Active: true},
+	}}
+	cl := bhdapi.New(bhdapi.WithApiKey(srv.ApiKey), bhdapi.WithTransport(srv.Transport()))
+	report, err := Reconcile(context.Background(), cl, tc)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	ids := func(mismatches []Mismatch) []int {
+		var v []int
+		for _, m := range mismatches {
+			v = append(v, m.Torrent.ID)
+		}
+		return v
+	}
+	for _, test := range []struct {
+		name       string
+		mismatches []Mismatch
+		exp        []int
+	}{
+		{"missing", report.Missing, []int{101233}},
+		{"unannounced", report.Unannounced, []int{162200, 190000}},
+		{"stopped", report.Stopped, []int{180777, 170555}},
+	} {
+		if v := ids(test.mismatches); !slices.Equal(v, test.exp) {
+			t.Errorf("expected %s %v, got: %v", test.name, test.exp, v)
+		}
+	}
+	if len(report.Stopped) == 2 && (report.Stopped[0].Client != nil || report.Stopped[1].Client == nil) {
+		t.Errorf("expected stopped client torrents to be set only when in the client")
+	}
+	// 2 seeding, 1 leeching, 5 completed and 1 incomplete pages, and 2 info
+	// hash lookups
+	if n := srv.Requests("search"); n != 11 {
+		t.Errorf("expected 11 search requests, got: %d", n)
+	}
+}
+
+// fakeClient is a fake torrent client.
+type fakeClient struct {
+	torrents []Torrent
+}
+
+func (tc *fakeClient) Add(context.Context, []byte, AddOptions) (string, bool, error) {
+	return "", false, nil
+}
+
+func (tc *fakeClient) Exists(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (tc *fakeClient) List(context.Context) ([]Torrent, error) {
+	return tc.torrents, nil
+}
@@ -0,0 +1,277 @@
+// Package deluge adds torrents to Deluge using its Web UI JSON-RPC API.
+package deluge
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/moistari/bhdapi/clients"
+)
+
+// Client is a Deluge Web UI JSON-RPC client.
+type Client struct {
+	URL       string
+	Password  string
+	Transport http.RoundTripper
+	cl        *http.Client
+	mu        sync.Mutex
+	id        int
+	loggedIn  bool
+}
+
+// New creates a new Deluge client for the Web UI url (http://host:8112).
+func New(urlstr string, opts ...Option) *Client {
+	cl := &Client{
+		URL: strings.TrimSuffix(urlstr, "/"),
+	}
+	for _, o := range opts {
+		o(cl)
+	}
+	if cl.cl == nil {
+		jar, _ := cookiejar.New(nil)
+		cl.cl = &http.Client{
+			Transport: cl.Transport,
+			Jar:       jar,
+		}
+	}
+	return cl
+}
+
+// Client satisfies the clients.TorrentClient interface.
+var _ clients.TorrentClient = (*Client)(nil)
+
+// Option is a Deluge client option.
+type Option func(cl *Client)
+
+// WithPassword is a client option to set the Web UI password.
+func WithPassword(password string) Option {
+	return func(cl *Client) {
+		cl.Password = password
+	}
+}
+
+// WithTransport is a client option to set the http transport used.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(cl *Client) {
+		cl.Transport = transport
+	}
+}
+
+// Add adds the torrent metainfo using core.add_torrent_file, returning its
+// info hash. When a torrent with the same info hash already exists, the
+// torrent is not added and exists is true. Deluge torrents have a single
+// label, which is set to the options' category, or the first tag, and
+// requires the Label plugin. Labels are lowercased, as the Label plugin only
+// allows lowercase labels. Skip checking adds the torrent in seed mode.
+func (cl *Client) Add(ctx context.Context, metainfo []byte, opts clients.AddOptions) (string, bool, error) {
+	infoHash, err := clients.InfoHash(metainfo)
+	if err != nil {
+		return "", false, err
+	}
+	exists, err := cl.Exists(ctx, infoHash)
+	if err != nil || exists {
+		return infoHash, exists, err
+	}
+	options := map[string]interface{}{
+		"add_paused": opts.Paused,
+		"seed_mode":  opts.SkipChecking,
+	}
+	if opts.SavePath != "" {
+		options["download_location"] = opts.SavePath
+	}
+	var hash string
+	if err := cl.Do(ctx, "core.add_torrent_file", &hash, infoHash+".torrent", base64.StdEncoding.EncodeToString(metainfo), options); err != nil {
+		return "", false, err
+	}
+	if hash == "" {
+		hash = infoHash
+	}
+	label := opts.Category
+	if label == "" && len(opts.Tags) != 0 {
+		label = opts.Tags[0]
+	}
+	if label = strings.ToLower(label); label != "" {
+		var re *rpcError
+		if err := cl.Do(ctx, "label.add", nil, label); err != nil && !(errors.As(err, &re) && re.Message == errLabelExists) {
+			return hash, false, err
+		}
+		if err := cl.Do(ctx, "label.set_torrent", nil, hash, label); err != nil {
+			return hash, false, err
+		}
+	}
+	return hash, false, nil
+}
+
+// Exists returns true when a torrent with the info hash exists.
+func (cl *Client) Exists(ctx context.Context, infoHash string) (bool, error) {
+	t, err := cl.Torrent(ctx, infoHash)
+	return t != nil, err
+}
+
+// Torrent is a Deluge torrent's status.
+type Torrent struct {
+	Hash         string  `json:"hash"`
+	Name         string  `json:"name"`
+	DownloadPath string  `json:"download_location"`
+	Label        string  `json:"label"`
+	State        string  `json:"state"`
+	Progress     float64 `json:"progress"`
+}
+
+// Torrent retrieves the status of the torrent with the info hash using
+// core.get_torrent_status. Returns nil when the torrent does not exist.
+func (cl *Client) Torrent(ctx context.Context, infoHash string) (*Torrent, error) {
+	var t Torrent
+	if err := cl.Do(ctx, "core.get_torrent_status", &t, strings.ToLower(infoHash), statusKeys); err != nil {
+		return nil, err
+	}
+	if t.Hash == "" {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// List returns the client's torrents using core.get_torrents_status.
+func (cl *Client) List(ctx context.Context) ([]clients.Torrent, error) {
+	var res map[string]Torrent
+	if err := cl.Do(ctx, "core.get_torrents_status", &res, map[string]interface{}{}, statusKeys); err != nil {
+		return nil, err
+	}
+	torrents := make([]clients.Torrent, 0, len(res))
+	for hash, t := range res {
+		torrents = append(torrents, clients.Torrent{
+			InfoHash: strings.ToLower(hash),
+			Name:     t.Name,
+			Complete: t.Progress >= 100,
+			Active:   t.State != "Paused" && t.State != "Error",
+		})
+	}
+	sort.Slice(torrents, func(i, j int) bool {
+		return torrents[i].InfoHash < torrents[j].InfoHash
+	})
+	return torrents, nil
+}
+
+// statusKeys are the torrent status keys retrieved.
+var statusKeys = []string{"hash", "name", "download_location", "label", "state", "progress"}
+
+// Do executes the JSON-RPC method with the params, decoding the result into
+// result. The client logs in, and connects the Web UI to the first daemon
+// host, when necessary.
+func (cl *Client) Do(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if !cl.loggedIn {
+		if err := cl.login(ctx); err != nil {
+			return err
+		}
+	}
+	err := cl.call(ctx, method, result, params...)
+	var re *rpcError
+	if errors.As(err, &re) && re.Code == errNotAuthenticated {
+		if err := cl.login(ctx); err != nil {
+			return err
+		}
+		err = cl.call(ctx, method, result, params...)
+	}
+	return err
+}
+
+// login logs in to the Web UI, and connects to the first daemon host when the
+// Web UI is not connected.
+func (cl *Client) login(ctx context.Context) error {
+	var ok bool
+	if err := cl.call(ctx, "auth.login", &ok, cl.Password); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	if !ok {
+		return errors.New("login: invalid password")
+	}
+	cl.loggedIn = true
+	var connected bool
+	if err := cl.call(ctx, "web.connected", &connected); err != nil || connected {
+		return err
+	}
+	var hosts [][]interface{}
+	if err := cl.call(ctx, "web.get_hosts", &hosts); err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return errors.New("connect: no daemon hosts")
+	}
+	return cl.call(ctx, "web.connect", nil, hosts[0][0])
+}
+
+// errNotAuthenticated is the error code returned when not authenticated.
+const errNotAuthenticated = 1
+
+// errLabelExists is the error message returned by label.add when the label
+// already exists.
+const errLabelExists = "Label already exists"
+
+// rpcError is a JSON-RPC error.
+type rpcError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// Error satisfies the error interface.
+func (err *rpcError) Error() string {
+	return err.Message
+}
+
+// call executes the JSON-RPC method.
+func (cl *Client) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	cl.id++
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"method": method,
+		"params": params,
+		"id":     cl.id,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", cl.URL+"/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := cl.cl.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: invalid http status %d", method, res.StatusCode)
+	}
+	var v struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 32<<20)).Decode(&v); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if v.Error != nil {
+		return fmt.Errorf("%s: %w", method, v.Error)
+	}
+	if result == nil || len(v.Result) == 0 || string(v.Result) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(v.Result, result); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	return nil
+}
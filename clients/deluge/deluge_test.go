@@ -0,0 +1,230 @@
+package deluge
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/moistari/bhdapi/bhdtest"
+	"github.com/moistari/bhdapi/clients"
+)
+
+func TestAdd(t *testing.T) {
+	srv := newServer("deluge")
+	defer srv.Close()
+	cl := New(srv.URL, WithPassword("deluge"))
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999", 38702381297)
+	ctx := context.Background()
+	opts := clients.AddOptions{
+		Category:     "Movies",
+		Tags:         []string{"bhd"},
+		SavePath:     "/data/movies",
+		Paused:       true,
+		SkipChecking: true,
+	}
+	infoHash, exists, err := cl.Add(ctx, buf, opts)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp:
+		t.Errorf("expected info hash %s, got: %s", exp, infoHash)
+	case exists:
+		t.Errorf("expected torrent to not exist")
+	}
+	if !srv.connected {
+		t.Errorf("expected web ui to be connected")
+	}
+	torrent, err := cl.Torrent(ctx, strings.ToUpper(exp))
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case torrent == nil:
+		t.Fatalf("expected torrent %s", exp)
+	case torrent.Label != "movies":
+		t.Errorf("expected label %q, got: %q", "movies", torrent.Label)
+	case torrent.DownloadPath != "/data/movies":
+		t.Errorf("expected download path %q, got: %q", "/data/movies", torrent.DownloadPath)
+	case torrent.State != "Paused":
+		t.Errorf("expected state %q, got: %q", "Paused", torrent.State)
+	}
+	// expire the session
+	srv.mu.Lock()
+	srv.session = "expired"
+	srv.mu.Unlock()
+	infoHash, exists, err = cl.Add(ctx, buf, clients.AddOptions{})
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp || !exists:
+		t.Errorf("expected existing torrent %s, got: %s %t", exp, infoHash, exists)
+	}
+	if srv.logins != 2 {
+		t.Errorf("expected 2 logins, got: %d", srv.logins)
+	}
+	torrents, err := cl.List(ctx)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(torrents) != 1 || torrents[0].InfoHash != exp || torrents[0].Active || torrents[0].Complete:
+		t.Errorf("expected paused incomplete torrent %s, got: %+v", exp, torrents)
+	}
+	if ok, err := cl.Exists(ctx, strings.Repeat("0", 40)); err != nil || ok {
+		t.Errorf("expected missing torrent, got: %t %v", ok, err)
+	}
+	buf, _ = bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "The.Matrix.1999", 4294967296)
+	if _, _, err := cl.Add(ctx, buf, clients.AddOptions{Category: "movies"}); err != nil {
+		t.Errorf("expected existing label to be set, got: %v", err)
+	}
+	buf, _ = bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Heat.1995", 4294967296)
+	if _, _, err := cl.Add(ctx, buf, clients.AddOptions{Category: "Bad Label"}); err == nil || !strings.Contains(err.Error(), "Invalid label") {
+		t.Errorf("expected invalid label error, got: %v", err)
+	}
+	if _, err := New(srv.URL, WithPassword("nope")).Exists(ctx, exp); err == nil {
+		t.Errorf("expected login error")
+	}
+}
+
+// labelRE matches valid Label plugin labels.
+var labelRE = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// server is a fake Deluge Web UI JSON-RPC server.
+type server struct {
+	*httptest.Server
+	password  string
+	mu        sync.Mutex
+	session   string
+	logins    int
+	connected bool
+	labels    map[string]bool
+	torrents  map[string]map[string]interface{}
+}
+
+// newServer creates a fake Deluge Web UI JSON-RPC server.
+func newServer(password string) *server {
+	s := &server{
+		password: password,
+		labels:   make(map[string]bool),
+		torrents: make(map[string]map[string]interface{}),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Method != "POST" || req.URL.Path != "/json" {
+		http.NotFound(w, req)
+		return
+	}
+	var v struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+		ID     int               `json:"id"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	param := func(i int, x interface{}) {
+		if i < len(v.Params) {
+			_ = json.Unmarshal(v.Params[i], x)
+		}
+	}
+	var result interface{}
+	var rpcErr *rpcError
+	if c, err := req.Cookie("_session_id"); v.Method != "auth.login" && (err != nil || c.Value != s.session) {
+		rpcErr = &rpcError{Message: "Not authenticated", Code: errNotAuthenticated}
+	} else {
+		switch v.Method {
+		case "auth.login":
+			var password string
+			param(0, &password)
+			ok := password == s.password
+			if ok {
+				s.logins++
+				s.session = "session" + strings.Repeat("x", s.logins)
+				http.SetCookie(w, &http.Cookie{Name: "_session_id", Value: s.session, Path: "/"})
+			}
+			result = ok
+		case "web.connected":
+			result = s.connected
+		case "web.get_hosts":
+			result = [][]interface{}{{"c4f3", "127.0.0.1", 58846, "localclient"}}
+		case "web.connect":
+			var id string
+			param(0, &id)
+			s.connected = id == "c4f3"
+		case "core.add_torrent_file":
+			var name, b64 string
+			var options map[string]interface{}
+			param(0, &name)
+			param(1, &b64)
+			param(2, &options)
+			buf, _ := base64.StdEncoding.DecodeString(b64)
+			infoHash, err := clients.InfoHash(buf)
+			if err != nil {
+				rpcErr = &rpcError{Message: err.Error(), Code: 4}
+				break
+			}
+			state := "Downloading"
+			if options["add_paused"] == true {
+				state = "Paused"
+			}
+			s.torrents[infoHash] = map[string]interface{}{
+				"hash":              infoHash,
+				"name":              name,
+				"download_location": options["download_location"],
+				"label":             "",
+				"state":             state,
+				"progress":          0.0,
+			}
+			result = infoHash
+		case "core.get_torrent_status":
+			var hash string
+			param(0, &hash)
+			m := map[string]interface{}{}
+			if torrent, ok := s.torrents[hash]; ok {
+				m = torrent
+			}
+			result = m
+		case "core.get_torrents_status":
+			result = s.torrents
+		case "label.add":
+			var label string
+			param(0, &label)
+			switch {
+			case !labelRE.MatchString(label):
+				rpcErr = &rpcError{Message: "Invalid label, valid characters:[a-z0-9_-]", Code: 4}
+			case s.labels[label]:
+				rpcErr = &rpcError{Message: "Label already exists", Code: 4}
+			}
+			s.labels[label] = true
+		case "label.set_torrent":
+			var hash, label string
+			param(0, &hash)
+			param(1, &label)
+			torrent, ok := s.torrents[hash]
+			if !ok || !s.labels[label] {
+				rpcErr = &rpcError{Message: "Unknown torrent or label", Code: 4}
+				break
+			}
+			torrent["label"] = label
+		default:
+			rpcErr = &rpcError{Message: "Unknown method", Code: 2}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"result": result,
+		"error":  rpcErr,
+		"id":     v.ID,
+	})
+}
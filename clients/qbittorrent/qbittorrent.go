@@ -0,0 +1,277 @@
+// Package qbittorrent adds torrents to qBittorrent using its Web API.
+package qbittorrent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/moistari/bhdapi/clients"
+)
+
+// Client is a qBittorrent Web API client.
+type Client struct {
+	URL       string
+	Username  string
+	Password  string
+	Transport http.RoundTripper
+	cl        *http.Client
+	mu        sync.Mutex
+	loggedIn  bool
+}
+
+// New creates a new qBittorrent client for the Web UI url.
+func New(urlstr string, opts ...Option) *Client {
+	cl := &Client{
+		URL: strings.TrimSuffix(urlstr, "/"),
+	}
+	for _, o := range opts {
+		o(cl)
+	}
+	if cl.cl == nil {
+		jar, _ := cookiejar.New(nil)
+		cl.cl = &http.Client{
+			Transport: cl.Transport,
+			Jar:       jar,
+		}
+	}
+	return cl
+}
+
+// Client satisfies the clients.TorrentClient interface.
+var _ clients.TorrentClient = (*Client)(nil)
+
+// Option is a qBittorrent client option.
+type Option func(cl *Client)
+
+// WithCredentials is a client option to set the Web UI username and password.
+// Without credentials, the client does not log in, as when qBittorrent is
+// configured to bypass authentication for the client's address.
+func WithCredentials(username, password string) Option {
+	return func(cl *Client) {
+		cl.Username, cl.Password = username, password
+	}
+}
+
+// WithTransport is a client option to set the http transport used.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(cl *Client) {
+		cl.Transport = transport
+	}
+}
+
+// Login logs in to the Web UI.
+func (cl *Client) Login(ctx context.Context) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	return cl.login(ctx)
+}
+
+// login logs in to the Web UI.
+func (cl *Client) login(ctx context.Context) error {
+	form := url.Values{
+		"username": {cl.Username},
+		"password": {cl.Password},
+	}
+	req, err := cl.newRequest(ctx, "POST", "/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	buf, err := cl.do(req)
+	switch {
+	case err != nil:
+		return fmt.Errorf("login: %w", err)
+	case strings.TrimSpace(string(buf)) != "Ok.":
+		return errors.New("login: invalid username or password")
+	}
+	cl.loggedIn = true
+	return nil
+}
+
+// Add adds the torrent metainfo, returning its info hash. When a torrent with
+// the same info hash already exists, the torrent is not added and exists is
+// true. The options' category, tags, save path, paused and skip checking
+// options are all supported.
+func (cl *Client) Add(ctx context.Context, metainfo []byte, opts clients.AddOptions) (string, bool, error) {
+	infoHash, err := clients.InfoHash(metainfo)
+	if err != nil {
+		return "", false, err
+	}
+	exists, err := cl.Exists(ctx, infoHash)
+	if err != nil || exists {
+		return infoHash, exists, err
+	}
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile("torrents", infoHash+".torrent")
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := part.Write(metainfo); err != nil {
+		return "", false, err
+	}
+	for _, f := range [][2]string{
+		{"category", opts.Category},
+		{"tags", strings.Join(opts.Tags, ",")},
+		{"savepath", opts.SavePath},
+		{"paused", strconv.FormatBool(opts.Paused)},
+		{"stopped", strconv.FormatBool(opts.Paused)},
+		{"skip_checking", strconv.FormatBool(opts.SkipChecking)},
+	} {
+		if f[1] == "" {
+			continue
+		}
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return "", false, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", false, err
+	}
+	buf, err := cl.send(ctx, "POST", "/api/v2/torrents/add", body.Bytes(), w.FormDataContentType())
+	if err != nil {
+		return "", false, fmt.Errorf("add: %w", err)
+	}
+	if s := strings.TrimSpace(string(buf)); s == "Fails." {
+		// older versions return Fails. for duplicate torrents
+		if exists, err := cl.Exists(ctx, infoHash); err == nil && exists {
+			return infoHash, true, nil
+		}
+		return "", false, errors.New("add: torrent was not added")
+	}
+	return infoHash, false, nil
+}
+
+// Exists returns true when a torrent with the info hash exists.
+func (cl *Client) Exists(ctx context.Context, infoHash string) (bool, error) {
+	buf, err := cl.send(ctx, "GET", "/api/v2/torrents/info?hashes="+url.QueryEscape(strings.ToLower(infoHash)), nil, "")
+	if err != nil {
+		return false, fmt.Errorf("info: %w", err)
+	}
+	var torrents []struct {
+		Hash string `json:"hash"`
+	}
+	if err := json.Unmarshal(buf, &torrents); err != nil {
+		return false, fmt.Errorf("info: %w", err)
+	}
+	return len(torrents) != 0, nil
+}
+
+// List returns the client's torrents.
+func (cl *Client) List(ctx context.Context) ([]clients.Torrent, error) {
+	buf, err := cl.send(ctx, "GET", "/api/v2/torrents/info", nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("info: %w", err)
+	}
+	var res []struct {
+		Hash     string  `json:"hash"`
+		Name     string  `json:"name"`
+		Progress float64 `json:"progress"`
+		State    string  `json:"state"`
+	}
+	if err := json.Unmarshal(buf, &res); err != nil {
+		return nil, fmt.Errorf("info: %w", err)
+	}
+	torrents := make([]clients.Torrent, 0, len(res))
+	for _, t := range res {
+		torrents = append(torrents, clients.Torrent{
+			InfoHash: strings.ToLower(t.Hash),
+			Name:     t.Name,
+			Complete: t.Progress >= 1,
+			Active:   !inactive[t.State],
+		})
+	}
+	return torrents, nil
+}
+
+// inactive are the torrent states that are not started.
+var inactive = map[string]bool{
+	"error":        true,
+	"missingFiles": true,
+	"pausedUP":     true,
+	"pausedDL":     true,
+	"stoppedUP":    true,
+	"stoppedDL":    true,
+	"unknown":      true,
+}
+
+// send sends a request, logging in first when necessary, and once more when
+// the session has expired.
+func (cl *Client) send(ctx context.Context, method, path string, body []byte, contentType string) ([]byte, error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for retry := true; ; retry = false {
+		if !cl.loggedIn && cl.Username != "" {
+			if err := cl.login(ctx); err != nil {
+				return nil, err
+			}
+		}
+		req, err := cl.newRequest(ctx, method, path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		buf, err := cl.do(req)
+		var se *statusError
+		if errors.As(err, &se) && se.code == http.StatusForbidden && retry && cl.Username != "" {
+			cl.loggedIn = false
+			continue
+		}
+		return buf, err
+	}
+}
+
+// newRequest creates a new request for the path.
+func (cl *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, cl.URL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	// required by the Web UI's csrf protection
+	req.Header.Set("Referer", cl.URL)
+	return req, nil
+}
+
+// do executes the request, returning the response body.
+func (cl *Client) do(req *http.Request) ([]byte, error) {
+	res, err := cl.cl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	buf, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, &statusError{code: res.StatusCode, msg: strings.TrimSpace(string(buf))}
+	}
+	return buf, nil
+}
+
+// statusError is a http status error.
+type statusError struct {
+	code int
+	msg  string
+}
+
+// Error satisfies the error interface.
+func (err *statusError) Error() string {
+	if err.msg != "" {
+		return fmt.Sprintf("invalid http status %d: %s", err.code, err.msg)
+	}
+	return fmt.Sprintf("invalid http status %d", err.code)
+}
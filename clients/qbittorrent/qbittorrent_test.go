@@ -0,0 +1,165 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/moistari/bhdapi/bhdtest"
+	"github.com/moistari/bhdapi/clients"
+)
+
+func TestAdd(t *testing.T) {
+	srv := newServer("admin", "adminadmin")
+	defer srv.Close()
+	cl := New(srv.URL, WithCredentials("admin", "adminadmin"))
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999", 38702381297)
+	ctx := context.Background()
+	opts := clients.AddOptions{
+		Category:     "movies",
+		Tags:         []string{"bhd", "remux"},
+		SavePath:     "/data/movies",
+		Paused:       true,
+		SkipChecking: true,
+	}
+	infoHash, exists, err := cl.Add(ctx, buf, opts)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp:
+		t.Errorf("expected info hash %s, got: %s", exp, infoHash)
+	case exists:
+		t.Errorf("expected torrent to not exist")
+	}
+	added := srv.torrents[exp]
+	for k, v := range map[string]string{
+		"category":      "movies",
+		"tags":          "bhd,remux",
+		"savepath":      "/data/movies",
+		"paused":        "true",
+		"stopped":       "true",
+		"skip_checking": "true",
+	} {
+		if added[k] != v {
+			t.Errorf("expected %s %q, got: %q", k, v, added[k])
+		}
+	}
+	// expire the session
+	srv.mu.Lock()
+	srv.sid = "expired"
+	srv.mu.Unlock()
+	infoHash, exists, err = cl.Add(ctx, buf, clients.AddOptions{})
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp || !exists:
+		t.Errorf("expected existing torrent %s, got: %s %t", exp, infoHash, exists)
+	}
+	torrents, err := cl.List(ctx)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(torrents) != 1 || torrents[0].InfoHash != exp || torrents[0].Active || torrents[0].Complete:
+		t.Errorf("expected paused incomplete torrent %s, got: %+v", exp, torrents)
+	}
+	if srv.logins != 2 {
+		t.Errorf("expected 2 logins, got: %d", srv.logins)
+	}
+	if _, _, err := cl.Add(ctx, []byte("not a torrent"), clients.AddOptions{}); err == nil {
+		t.Errorf("expected error for invalid metainfo")
+	}
+	if err := New(srv.URL, WithCredentials("admin", "nope")).Login(ctx); err == nil {
+		t.Errorf("expected login error")
+	}
+}
+
+// server is a fake qBittorrent Web API server.
+type server struct {
+	*httptest.Server
+	username, password string
+	mu                 sync.Mutex
+	sid                string
+	logins             int
+	torrents           map[string]map[string]string
+}
+
+// newServer creates a fake qBittorrent Web API server.
+func newServer(username, password string) *server {
+	s := &server{
+		username: username,
+		password: password,
+		torrents: make(map[string]map[string]string),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Header.Get("Referer") != s.URL {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.URL.Path == "/api/v2/auth/login" {
+		if req.FormValue("username") != s.username || req.FormValue("password") != s.password {
+			io.WriteString(w, "Fails.")
+			return
+		}
+		s.logins++
+		s.sid = "sid" + strings.Repeat("x", s.logins)
+		http.SetCookie(w, &http.Cookie{Name: "SID", Value: s.sid, Path: "/"})
+		io.WriteString(w, "Ok.")
+		return
+	}
+	if c, err := req.Cookie("SID"); err != nil || c.Value != s.sid {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	switch req.URL.Path {
+	case "/api/v2/torrents/info":
+		var torrents []map[string]interface{}
+		for hash, t := range s.torrents {
+			if hashes := req.URL.Query().Get("hashes"); hashes != "" && !strings.Contains(hashes, hash) {
+				continue
+			}
+			state := "stalledDL"
+			if t["paused"] == "true" {
+				state = "pausedDL"
+			}
+			torrents = append(torrents, map[string]interface{}{"hash": hash, "name": "torrent", "progress": 0, "state": state})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(append([]map[string]interface{}{}, torrents...))
+	case "/api/v2/torrents/add":
+		f, _, err := req.FormFile("torrents")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		buf, _ := io.ReadAll(f)
+		infoHash, err := clients.InfoHash(buf)
+		if err != nil {
+			http.Error(w, "", http.StatusUnsupportedMediaType)
+			return
+		}
+		if _, ok := s.torrents[infoHash]; ok {
+			io.WriteString(w, "Fails.")
+			return
+		}
+		m := make(map[string]string)
+		for k, v := range req.MultipartForm.Value {
+			m[k] = v[0]
+		}
+		s.torrents[infoHash] = m
+		io.WriteString(w, "Ok.")
+	default:
+		http.NotFound(w, req)
+	}
+}
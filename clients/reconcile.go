@@ -0,0 +1,133 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+)
+
+// Report is a seeding status report, comparing the torrents bhd reports for
+// the user against a torrent client's torrents.
+type Report struct {
+	// Missing are the torrents bhd reports as seeding or leeching that are not
+	// in the torrent client.
+	Missing []Mismatch `json:"missing"`
+	// Unannounced are the bhd torrents that are active in the torrent client,
+	// but that bhd does not report as seeding or leeching.
+	Unannounced []Mismatch `json:"unannounced"`
+	// Stopped are the torrents bhd reports as completed that are not seeding,
+	// and that are stopped in or removed from the torrent client.
+	Stopped []Mismatch `json:"stopped"`
+}
+
+// Mismatch is a torrent whose bhd and torrent client status do not match.
+type Mismatch struct {
+	// Torrent is the bhd torrent.
+	Torrent bhdapi.Torrent `json:"torrent"`
+	// Client is the torrent client's torrent, or nil when the torrent is not
+	// in the torrent client.
+	Client *Torrent `json:"client,omitempty"`
+}
+
+// Reconcile compares the torrents bhd reports as seeding, leeching, completed
+// and incomplete for the user against the torrent client's torrents, matching
+// them by info hash. Active torrent client torrents that are in none of
+// those lists are looked up on bhd by info hash, with one search request
+// each, and are reported as unannounced when found. Those not found, such as
+// torrents from other trackers, are ignored.
+func Reconcile(ctx context.Context, cl *bhdapi.Client, tc TorrentClient) (*Report, error) {
+	seeding, err := infoHashes(ctx, cl, bhdapi.Search().WithSeeding(true))
+	if err != nil {
+		return nil, err
+	}
+	leeching, err := infoHashes(ctx, cl, bhdapi.Search().WithLeeching(true))
+	if err != nil {
+		return nil, err
+	}
+	completed, err := infoHashes(ctx, cl, bhdapi.Search().WithCompleted(true))
+	if err != nil {
+		return nil, err
+	}
+	incomplete, err := infoHashes(ctx, cl, bhdapi.Search().WithIncomplete(true))
+	if err != nil {
+		return nil, err
+	}
+	list, err := tc.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list: %w", err)
+	}
+	torrents := make(map[string]*Torrent, len(list))
+	for i := range list {
+		torrents[strings.ToLower(list[i].InfoHash)] = &list[i]
+	}
+	report := new(Report)
+	for _, set := range []*hashSet{seeding, leeching} {
+		for _, hash := range set.order {
+			if torrents[hash] == nil {
+				report.Missing = append(report.Missing, Mismatch{Torrent: set.torrents[hash]})
+			}
+		}
+	}
+	for _, set := range []*hashSet{completed, incomplete} {
+		for _, hash := range set.order {
+			if seeding.has(hash) || leeching.has(hash) {
+				continue
+			}
+			m := Mismatch{Torrent: set.torrents[hash], Client: torrents[hash]}
+			switch {
+			case m.Client != nil && m.Client.Active:
+				report.Unannounced = append(report.Unannounced, m)
+			case set == completed:
+				report.Stopped = append(report.Stopped, m)
+			}
+		}
+	}
+	for _, t := range list {
+		hash := strings.ToLower(t.InfoHash)
+		if !t.Active || seeding.has(hash) || leeching.has(hash) || completed.has(hash) || incomplete.has(hash) {
+			continue
+		}
+		res, err := bhdapi.Search().WithInfoHash(hash).Do(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Results) != 0 {
+			report.Unannounced = append(report.Unannounced, Mismatch{Torrent: res.Results[0], Client: torrents[hash]})
+		}
+	}
+	return report, nil
+}
+
+// hashSet is an ordered set of bhd torrents keyed by info hash.
+type hashSet struct {
+	order    []string
+	torrents map[string]bhdapi.Torrent
+}
+
+// has returns true when the set contains the info hash.
+func (set *hashSet) has(hash string) bool {
+	_, ok := set.torrents[hash]
+	return ok
+}
+
+// infoHashes returns the set of torrents for all pages of the search request.
+func infoHashes(ctx context.Context, cl *bhdapi.Client, req *bhdapi.SearchRequest) (*hashSet, error) {
+	set := &hashSet{
+		torrents: make(map[string]bhdapi.Torrent),
+	}
+	for req.Next(ctx, cl) {
+		t := req.Cur()
+		hash := strings.ToLower(t.InfoHash)
+		if hash == "" || set.has(hash) {
+			continue
+		}
+		set.order = append(set.order, hash)
+		set.torrents[hash] = t
+	}
+	if err := req.Err(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
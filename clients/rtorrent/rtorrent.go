@@ -0,0 +1,455 @@
+// Package rtorrent adds torrents to rTorrent using its XML-RPC interface, over
+// HTTP or SCGI.
+package rtorrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/moistari/bhdapi/clients"
+)
+
+// Client is a rTorrent XML-RPC client.
+type Client struct {
+	URL       string
+	Username  string
+	Password  string
+	Transport http.RoundTripper
+	cl        *http.Client
+	dialer    net.Dialer
+}
+
+// New creates a new rTorrent client for the url. Urls with a http or https
+// scheme are sent to a XML-RPC HTTP endpoint (http://host/RPC2). Urls with a
+// scgi scheme are sent directly to rTorrent's scgi_port (scgi://host:5000) or
+// scgi_local socket (scgi:///path/to/rpc.socket).
+func New(urlstr string, opts ...Option) *Client {
+	cl := &Client{
+		URL: urlstr,
+	}
+	for _, o := range opts {
+		o(cl)
+	}
+	if cl.cl == nil {
+		cl.cl = &http.Client{
+			Transport: cl.Transport,
+		}
+	}
+	return cl
+}
+
+// Client satisfies the clients.TorrentClient interface.
+var _ clients.TorrentClient = (*Client)(nil)
+
+// Option is a rTorrent client option.
+type Option func(cl *Client)
+
+// WithCredentials is a client option to set the HTTP basic auth username and
+// password.
+func WithCredentials(username, password string) Option {
+	return func(cl *Client) {
+		cl.Username, cl.Password = username, password
+	}
+}
+
+// WithTransport is a client option to set the http transport used.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(cl *Client) {
+		cl.Transport = transport
+	}
+}
+
+// Add adds the torrent metainfo using load.raw_start_verbose, or
+// load.raw_verbose when paused, returning its info hash. When a torrent with
+// the same info hash already exists, the torrent is not added and exists is
+// true. The label (d.custom1, as used by ruTorrent) is set to the options'
+// category, or the tags when there is no category. Skip checking is not
+// supported.
+func (cl *Client) Add(ctx context.Context, metainfo []byte, opts clients.AddOptions) (string, bool, error) {
+	infoHash, err := clients.InfoHash(metainfo)
+	if err != nil {
+		return "", false, err
+	}
+	exists, err := cl.Exists(ctx, infoHash)
+	if err != nil || exists {
+		return infoHash, exists, err
+	}
+	method := "load.raw_start_verbose"
+	if opts.Paused {
+		method = "load.raw_verbose"
+	}
+	params := []interface{}{"", metainfo}
+	if opts.SavePath != "" {
+		params = append(params, `d.directory.set="`+strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(opts.SavePath)+`"`)
+	}
+	label := opts.Category
+	if label == "" {
+		label = strings.Join(opts.Tags, ",")
+	}
+	if label != "" {
+		params = append(params, "d.custom1.set="+url.PathEscape(label))
+	}
+	if _, err := cl.Call(ctx, method, params...); err != nil {
+		return "", false, err
+	}
+	return infoHash, false, nil
+}
+
+// Exists returns true when a torrent with the info hash exists.
+func (cl *Client) Exists(ctx context.Context, infoHash string) (bool, error) {
+	_, err := cl.Call(ctx, "d.hash", strings.ToUpper(infoHash))
+	var f *Fault
+	if errors.As(err, &f) && f.notFound() {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Torrent is a rTorrent torrent.
+type Torrent struct {
+	Hash      string
+	Name      string
+	Directory string
+	Label     string
+	Complete  bool
+	State     int
+}
+
+// Torrent retrieves the torrent with the info hash. Returns nil when the
+// torrent does not exist.
+func (cl *Client) Torrent(ctx context.Context, infoHash string) (*Torrent, error) {
+	hash := strings.ToUpper(infoHash)
+	calls := []interface{}{}
+	for _, method := range []string{"d.hash", "d.name", "d.directory", "d.custom1", "d.complete", "d.state"} {
+		calls = append(calls, map[string]interface{}{
+			"methodName": method,
+			"params":     []interface{}{hash},
+		})
+	}
+	v, err := cl.Call(ctx, "system.multicall", calls)
+	if err != nil {
+		return nil, err
+	}
+	results, _ := v.([]interface{})
+	if len(results) != len(calls) {
+		return nil, errors.New("system.multicall: unexpected results")
+	}
+	values := make([]interface{}, len(results))
+	for i, r := range results {
+		switch x := r.(type) {
+		case []interface{}:
+			if len(x) == 1 {
+				values[i] = x[0]
+			}
+		case map[string]interface{}:
+			if f := (&Fault{Code: int(num(x["faultCode"])), String: str(x["faultString"])}); !f.notFound() {
+				return nil, fmt.Errorf("%s: %w", calls[i].(map[string]interface{})["methodName"], f)
+			}
+			return nil, nil
+		}
+	}
+	t := &Torrent{
+		Hash:      str(values[0]),
+		Name:      str(values[1]),
+		Directory: str(values[2]),
+		Complete:  num(values[4]) == 1,
+		State:     int(num(values[5])),
+	}
+	t.Label, _ = url.PathUnescape(str(values[3]))
+	return t, nil
+}
+
+// List returns the client's torrents using d.multicall2. Paused torrents
+// keep d.state=1, so d.is_active is used for the active state.
+func (cl *Client) List(ctx context.Context) ([]clients.Torrent, error) {
+	v, err := cl.Call(ctx, "d.multicall2", "", "main", "d.hash=", "d.name=", "d.complete=", "d.is_active=")
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := v.([]interface{})
+	torrents := make([]clients.Torrent, 0, len(rows))
+	for _, row := range rows {
+		values, _ := row.([]interface{})
+		if len(values) != 4 {
+			return nil, errors.New("d.multicall2: unexpected results")
+		}
+		torrents = append(torrents, clients.Torrent{
+			InfoHash: strings.ToLower(str(values[0])),
+			Name:     str(values[1]),
+			Complete: num(values[2]) == 1,
+			Active:   num(values[3]) == 1,
+		})
+	}
+	return torrents, nil
+}
+
+// Fault is a XML-RPC fault.
+type Fault struct {
+	Code   int
+	String string
+}
+
+// Error satisfies the error interface.
+func (f *Fault) Error() string {
+	return fmt.Sprintf("fault %d: %s", f.Code, f.String)
+}
+
+// notFound returns true when the fault is rTorrent's fault for an unknown
+// info hash.
+func (f *Fault) notFound() bool {
+	return f.Code == -501 && f.String == "Could not find info-hash."
+}
+
+// Call executes the XML-RPC method with the params, returning the decoded
+// result. Params may be strings, ints, bools, []byte (base64), slices and
+// maps. Results are decoded as string, int64, bool, []byte, []interface{} and
+// map[string]interface{} values. A XML-RPC fault is returned as a *Fault.
+func (cl *Client) Call(ctx context.Context, method string, params ...interface{}) (interface{}, error) {
+	body := new(bytes.Buffer)
+	body.WriteString(xml.Header + "<methodCall><methodName>")
+	_ = xml.EscapeText(body, []byte(method))
+	body.WriteString("</methodName><params>")
+	for _, p := range params {
+		body.WriteString("<param>")
+		if err := encode(body, p); err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+		body.WriteString("</param>")
+	}
+	body.WriteString("</params></methodCall>")
+	var r io.ReadCloser
+	var err error
+	if strings.HasPrefix(cl.URL, "scgi://") {
+		r, err = cl.scgi(ctx, body.Bytes())
+	} else {
+		r, err = cl.http(ctx, body.Bytes())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	defer r.Close()
+	v, err := decodeResponse(io.LimitReader(r, 32<<20))
+	if err != nil {
+		var f *Fault
+		if errors.As(err, &f) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+	return v, nil
+}
+
+// http sends the request body to the XML-RPC HTTP endpoint.
+func (cl *Client) http(ctx context.Context, body []byte) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", cl.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if cl.Username != "" || cl.Password != "" {
+		req.SetBasicAuth(cl.Username, cl.Password)
+	}
+	res, err := cl.cl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("invalid http status %d", res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+// scgi sends the request body to the SCGI endpoint.
+func (cl *Client) scgi(ctx context.Context, body []byte) (io.ReadCloser, error) {
+	u, err := url.Parse(cl.URL)
+	if err != nil {
+		return nil, err
+	}
+	network, addr := "tcp", u.Host
+	if u.Host == "" {
+		network, addr = "unix", u.Path
+	}
+	conn, err := cl.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	headers := "CONTENT_LENGTH\x00" + strconv.Itoa(len(body)) + "\x00SCGI\x001\x00REQUEST_METHOD\x00POST\x00REQUEST_URI\x00/RPC2\x00"
+	if _, err := fmt.Fprintf(conn, "%d:%s,%s", len(headers), headers, body); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// skip the response headers
+	br := bufio.NewReader(conn)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if k, v, _ := strings.Cut(line, ":"); strings.EqualFold(k, "Status") && !strings.HasPrefix(strings.TrimSpace(v), "200") {
+			conn.Close()
+			return nil, fmt.Errorf("invalid scgi status %s", strings.TrimSpace(v))
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{br, conn}, nil
+}
+
+// encode encodes v as a XML-RPC value.
+func encode(w *bytes.Buffer, v interface{}) error {
+	w.WriteString("<value>")
+	switch x := v.(type) {
+	case string:
+		w.WriteString("<string>")
+		_ = xml.EscapeText(w, []byte(x))
+		w.WriteString("</string>")
+	case int:
+		fmt.Fprintf(w, "<i8>%d</i8>", x)
+	case int64:
+		fmt.Fprintf(w, "<i8>%d</i8>", x)
+	case bool:
+		w.WriteString(map[bool]string{true: "<boolean>1</boolean>", false: "<boolean>0</boolean>"}[x])
+	case []byte:
+		w.WriteString("<base64>" + base64.StdEncoding.EncodeToString(x) + "</base64>")
+	case []interface{}:
+		w.WriteString("<array><data>")
+		for _, y := range x {
+			if err := encode(w, y); err != nil {
+				return err
+			}
+		}
+		w.WriteString("</data></array>")
+	case map[string]interface{}:
+		w.WriteString("<struct>")
+		for k, y := range x {
+			w.WriteString("<member><name>")
+			_ = xml.EscapeText(w, []byte(k))
+			w.WriteString("</name>")
+			if err := encode(w, y); err != nil {
+				return err
+			}
+			w.WriteString("</member>")
+		}
+		w.WriteString("</struct>")
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	w.WriteString("</value>")
+	return nil
+}
+
+// value is a XML-RPC value.
+type value struct {
+	Inner  string   `xml:",chardata"`
+	String *string  `xml:"string"`
+	Int    *string  `xml:"int"`
+	I4     *string  `xml:"i4"`
+	I8     *string  `xml:"i8"`
+	Bool   *string  `xml:"boolean"`
+	Base64 *string  `xml:"base64"`
+	Double *string  `xml:"double"`
+	Array  *[]value `xml:"array>data>value"`
+	Struct *[]struct {
+		Name  string `xml:"name"`
+		Value value  `xml:"value"`
+	} `xml:"struct>member"`
+}
+
+// decodeResponse decodes a XML-RPC method response.
+func decodeResponse(r io.Reader) (interface{}, error) {
+	var res struct {
+		Params []value `xml:"params>param>value"`
+		Fault  *value  `xml:"fault>value"`
+	}
+	if err := xml.NewDecoder(r).Decode(&res); err != nil {
+		return nil, err
+	}
+	if res.Fault != nil {
+		v, err := res.Fault.decode()
+		if err != nil {
+			return nil, err
+		}
+		m, _ := v.(map[string]interface{})
+		return nil, &Fault{Code: int(num(m["faultCode"])), String: str(m["faultString"])}
+	}
+	if len(res.Params) != 1 {
+		return nil, errors.New("expected single response param")
+	}
+	return res.Params[0].decode()
+}
+
+// decode decodes the value.
+func (v value) decode() (interface{}, error) {
+	switch {
+	case v.String != nil:
+		return *v.String, nil
+	case v.Int != nil, v.I4 != nil, v.I8 != nil:
+		s := v.Int
+		if s == nil {
+			if s = v.I4; s == nil {
+				s = v.I8
+			}
+		}
+		return strconv.ParseInt(strings.TrimSpace(*s), 10, 64)
+	case v.Bool != nil:
+		return strings.TrimSpace(*v.Bool) == "1", nil
+	case v.Base64 != nil:
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(*v.Base64))
+	case v.Double != nil:
+		return strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+	case v.Array != nil:
+		values := make([]interface{}, 0, len(*v.Array))
+		for _, x := range *v.Array {
+			y, err := x.decode()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, y)
+		}
+		return values, nil
+	case v.Struct != nil:
+		m := make(map[string]interface{}, len(*v.Struct))
+		for _, member := range *v.Struct {
+			y, err := member.Value.decode()
+			if err != nil {
+				return nil, err
+			}
+			m[member.Name] = y
+		}
+		return m, nil
+	}
+	// untyped values are strings
+	return v.Inner, nil
+}
+
+// str returns v as a string.
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// num returns v as an int64.
+func num(v interface{}) int64 {
+	n, _ := v.(int64)
+	return n
+}
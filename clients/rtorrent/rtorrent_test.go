@@ -0,0 +1,326 @@
+package rtorrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/moistari/bhdapi/bhdtest"
+	"github.com/moistari/bhdapi/clients"
+)
+
+func TestAdd(t *testing.T) {
+	srv := newServer()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if username, password, _ := req.BasicAuth(); username != "user" || password != "pass" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		srv.serve(w, req.Body)
+	}))
+	defer ts.Close()
+	testAdd(t, srv, New(ts.URL+"/RPC2", WithCredentials("user", "pass")))
+	if _, err := New(ts.URL+"/RPC2").Exists(context.Background(), strings.Repeat("0", 40)); err == nil {
+		t.Errorf("expected http status error")
+	}
+}
+
+func TestSCGI(t *testing.T) {
+	srv := newServer()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serveSCGI(conn)
+		}
+	}()
+	testAdd(t, srv, New("scgi://"+l.Addr().String()))
+}
+
+func testAdd(t *testing.T, srv *server, cl *Client) {
+	t.Helper()
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999", 38702381297)
+	ctx := context.Background()
+	opts := clients.AddOptions{
+		Category: "movies/remux",
+		SavePath: `/data/"movies"`,
+		Paused:   true,
+	}
+	infoHash, exists, err := cl.Add(ctx, buf, opts)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp:
+		t.Errorf("expected info hash %s, got: %s", exp, infoHash)
+	case exists:
+		t.Errorf("expected torrent to not exist")
+	}
+	torrent, err := cl.Torrent(ctx, exp)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case torrent == nil:
+		t.Fatalf("expected torrent %s", exp)
+	case torrent.Hash != strings.ToUpper(exp):
+		t.Errorf("expected hash %s, got: %s", strings.ToUpper(exp), torrent.Hash)
+	case torrent.Label != "movies/remux":
+		t.Errorf("expected label %q, got: %q", "movies/remux", torrent.Label)
+	case torrent.Directory != `/data/"movies"`:
+		t.Errorf("expected directory %q, got: %q", `/data/"movies"`, torrent.Directory)
+	case torrent.State != 0:
+		t.Errorf("expected stopped torrent, got state: %d", torrent.State)
+	}
+	infoHash, exists, err = cl.Add(ctx, buf, clients.AddOptions{})
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case infoHash != exp || !exists:
+		t.Errorf("expected existing torrent %s, got: %s %t", exp, infoHash, exists)
+	}
+	torrents, err := cl.List(ctx)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(torrents) != 1 || torrents[0].InfoHash != exp || torrents[0].Active || torrents[0].Complete:
+		t.Errorf("expected stopped incomplete torrent %s, got: %+v", exp, torrents)
+	}
+	buf, started := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "The.Matrix.1999", 24696061952)
+	if _, _, err := cl.Add(ctx, buf, clients.AddOptions{}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, method := range []string{"", "d.pause", "d.resume"} {
+		if method != "" {
+			if _, err := cl.Call(ctx, method, strings.ToUpper(started)); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+		}
+		torrents, err := cl.List(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		for _, torrent := range torrents {
+			if torrent.InfoHash == started && torrent.Active != (method != "d.pause") {
+				t.Errorf("%s: expected active %t, got: %+v", method, method != "d.pause", torrent)
+			}
+		}
+		if torrent, err := cl.Torrent(ctx, started); err != nil || torrent.State != 1 {
+			t.Errorf("%s: expected started torrent, got: %+v %v", method, torrent, err)
+		}
+	}
+	if torrent, err := cl.Torrent(ctx, strings.Repeat("0", 40)); err != nil || torrent != nil {
+		t.Errorf("expected missing torrent, got: %v %v", torrent, err)
+	}
+	if _, err := cl.Call(ctx, "nope"); err == nil {
+		t.Errorf("expected fault")
+	}
+	srv.mu.Lock()
+	srv.fault = &Fault{-503, "internal error"}
+	srv.mu.Unlock()
+	if ok, err := cl.Exists(ctx, exp); err == nil || ok {
+		t.Errorf("expected fault, got: %t %v", ok, err)
+	}
+	if torrent, err := cl.Torrent(ctx, exp); err == nil || torrent != nil {
+		t.Errorf("expected fault, got: %v %v", torrent, err)
+	}
+}
+
+// server is a fake rTorrent XML-RPC server.
+type server struct {
+	mu       sync.Mutex
+	torrents map[string]map[string]interface{}
+	fault    *Fault
+}
+
+// newServer creates a fake rTorrent XML-RPC server.
+func newServer() *server {
+	return &server{
+		torrents: make(map[string]map[string]interface{}),
+	}
+}
+
+// serveSCGI serves a XML-RPC request on a SCGI connection.
+func (s *server) serveSCGI(conn net.Conn) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	n, err := br.ReadString(':')
+	if err != nil {
+		return
+	}
+	length, _ := strconv.Atoi(strings.TrimSuffix(n, ":"))
+	headers := make([]byte, length+1)
+	if _, err := io.ReadFull(br, headers); err != nil {
+		return
+	}
+	fields := strings.Split(string(headers[:length]), "\x00")
+	var contentLength int
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] == "CONTENT_LENGTH" {
+			contentLength, _ = strconv.Atoi(fields[i+1])
+		}
+	}
+	var buf bytes.Buffer
+	s.serve(&buf, io.LimitReader(br, int64(contentLength)))
+	fmt.Fprintf(conn, "Status: 200 OK\r\nContent-Type: text/xml\r\nContent-Length: %d\r\n\r\n", buf.Len())
+	_, _ = conn.Write(buf.Bytes())
+}
+
+// serve serves a XML-RPC request.
+func (s *server) serve(w io.Writer, r io.Reader) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var call struct {
+		MethodName string  `xml:"methodName"`
+		Params     []value `xml:"params>param>value"`
+	}
+	if err := xml.NewDecoder(r).Decode(&call); err != nil {
+		writeFault(w, -503, err.Error())
+		return
+	}
+	params := make([]interface{}, len(call.Params))
+	for i, p := range call.Params {
+		var err error
+		if params[i], err = p.decode(); err != nil {
+			writeFault(w, -503, err.Error())
+			return
+		}
+	}
+	v, fault := s.call(call.MethodName, params)
+	if fault != nil {
+		writeFault(w, fault.Code, fault.String)
+		return
+	}
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		writeFault(w, -503, err.Error())
+		return
+	}
+	fmt.Fprintf(w, "%s<methodResponse><params><param>%s</param></params></methodResponse>", xml.Header, buf.String())
+}
+
+// call executes the method.
+func (s *server) call(method string, params []interface{}) (interface{}, *Fault) {
+	switch method {
+	case "system.multicall":
+		calls, _ := params[0].([]interface{})
+		var results []interface{}
+		for _, c := range calls {
+			m, _ := c.(map[string]interface{})
+			p, _ := m["params"].([]interface{})
+			v, fault := s.call(str(m["methodName"]), p)
+			if fault != nil {
+				results = append(results, map[string]interface{}{"faultCode": fault.Code, "faultString": fault.String})
+				continue
+			}
+			results = append(results, []interface{}{v})
+		}
+		return results, nil
+	case "load.raw_verbose", "load.raw_start_verbose":
+		if len(params) < 2 || str(params[0]) != "" {
+			return nil, &Fault{-503, "invalid params"}
+		}
+		buf, _ := params[1].([]byte)
+		infoHash, err := clients.InfoHash(buf)
+		if err != nil {
+			return nil, &Fault{-503, "could not create download"}
+		}
+		hash := strings.ToUpper(infoHash)
+		torrent := map[string]interface{}{
+			"d.hash":      hash,
+			"d.name":      "torrent",
+			"d.directory": "/downloads",
+			"d.custom1":   "",
+			"d.complete":  int64(0),
+			"d.state":     int64(0),
+			"d.is_open":   int64(0),
+			"d.is_active": int64(0),
+		}
+		if method == "load.raw_start_verbose" {
+			torrent["d.state"], torrent["d.is_open"], torrent["d.is_active"] = int64(1), int64(1), int64(1)
+		}
+		for _, p := range params[2:] {
+			cmd, arg, _ := strings.Cut(str(p), "=")
+			switch cmd {
+			case "d.directory.set":
+				arg, _ = strconv.Unquote(arg)
+				torrent["d.directory"] = arg
+			case "d.custom1.set":
+				torrent["d.custom1"] = arg
+			default:
+				return nil, &Fault{-503, "unknown command " + cmd}
+			}
+		}
+		s.torrents[hash] = torrent
+		return int64(0), nil
+	case "d.multicall2":
+		if len(params) < 2 || str(params[1]) != "main" {
+			return nil, &Fault{-503, "invalid params"}
+		}
+		hashes := make([]string, 0, len(s.torrents))
+		for hash := range s.torrents {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+		var rows []interface{}
+		for _, hash := range hashes {
+			var row []interface{}
+			for _, p := range params[2:] {
+				row = append(row, s.torrents[hash][strings.TrimSuffix(str(p), "=")])
+			}
+			rows = append(rows, row)
+		}
+		return append([]interface{}{}, rows...), nil
+	case "d.pause", "d.resume":
+		if len(params) != 1 {
+			return nil, &Fault{-503, "invalid params"}
+		}
+		torrent, ok := s.torrents[str(params[0])]
+		if !ok {
+			return nil, &Fault{-501, "Could not find info-hash."}
+		}
+		// pausing leaves the torrent open and started
+		torrent["d.is_active"] = int64(0)
+		if method == "d.resume" {
+			torrent["d.is_active"] = int64(1)
+		}
+		return int64(0), nil
+	case "d.hash", "d.name", "d.directory", "d.custom1", "d.complete", "d.state":
+		if len(params) != 1 {
+			return nil, &Fault{-503, "invalid params"}
+		}
+		if s.fault != nil {
+			return nil, s.fault
+		}
+		torrent, ok := s.torrents[str(params[0])]
+		if !ok {
+			return nil, &Fault{-501, "Could not find info-hash."}
+		}
+		return torrent[method], nil
+	}
+	return nil, &Fault{-506, "Method '" + method + "' not defined"}
+}
+
+// writeFault writes a XML-RPC fault.
+func writeFault(w io.Writer, code int, msg string) {
+	var buf bytes.Buffer
+	_ = encode(&buf, map[string]interface{}{"faultCode": code, "faultString": msg})
+	fmt.Fprintf(w, "%s<methodResponse><fault>%s</fault></methodResponse>", xml.Header, buf.String())
+}
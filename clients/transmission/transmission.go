@@ -0,0 +1,239 @@
+// Package transmission adds torrents to Transmission using its RPC protocol.
+package transmission
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/moistari/bhdapi/clients"
+)
+
+// sessionHeader is the rpc session id header.
+const sessionHeader = "X-Transmission-Session-Id"
+
+// Client is a Transmission rpc client.
+type Client struct {
+	URL       string
+	Username  string
+	Password  string
+	Transport http.RoundTripper
+	cl        *http.Client
+	mu        sync.Mutex
+	sessionID string
+}
+
+// New creates a new Transmission client for the rpc url
+// (http://host:9091/transmission/rpc).
+func New(urlstr string, opts ...Option) *Client {
+	cl := &Client{
+		URL: urlstr,
+	}
+	for _, o := range opts {
+		o(cl)
+	}
+	if cl.cl == nil {
+		cl.cl = &http.Client{
+			Transport: cl.Transport,
+		}
+	}
+	return cl
+}
+
+// Client satisfies the clients.TorrentClient interface.
+var _ clients.TorrentClient = (*Client)(nil)
+
+// Option is a Transmission client option.
+type Option func(cl *Client)
+
+// WithCredentials is a client option to set the rpc username and password.
+func WithCredentials(username, password string) Option {
+	return func(cl *Client) {
+		cl.Username, cl.Password = username, password
+	}
+}
+
+// WithTransport is a client option to set the http transport used.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(cl *Client) {
+		cl.Transport = transport
+	}
+}
+
+// Torrent is a Transmission torrent.
+type Torrent struct {
+	ID          int      `json:"id"`
+	HashString  string   `json:"hashString"`
+	Name        string   `json:"name"`
+	DownloadDir string   `json:"downloadDir"`
+	Labels      []string `json:"labels"`
+	Status      int      `json:"status"`
+	PercentDone float64  `json:"percentDone"`
+}
+
+// Add adds the torrent metainfo using torrent-add, returning its info hash.
+// When a torrent with the same info hash already exists, the torrent is not
+// added and exists is true. The options' category and tags are added as
+// labels. Skip checking is not supported.
+func (cl *Client) Add(ctx context.Context, metainfo []byte, opts clients.AddOptions) (string, bool, error) {
+	if _, err := clients.InfoHash(metainfo); err != nil {
+		return "", false, err
+	}
+	args := map[string]interface{}{
+		"metainfo": base64.StdEncoding.EncodeToString(metainfo),
+		"paused":   opts.Paused,
+	}
+	if opts.SavePath != "" {
+		args["download-dir"] = opts.SavePath
+	}
+	var labels []string
+	if opts.Category != "" {
+		labels = append(labels, opts.Category)
+	}
+	if labels = append(labels, opts.Tags...); len(labels) != 0 {
+		args["labels"] = labels
+	}
+	var res struct {
+		Added     *Torrent `json:"torrent-added"`
+		Duplicate *Torrent `json:"torrent-duplicate"`
+	}
+	if err := cl.Do(ctx, "torrent-add", args, &res); err != nil {
+		return "", false, err
+	}
+	switch {
+	case res.Duplicate != nil:
+		return res.Duplicate.HashString, true, nil
+	case res.Added != nil:
+		return res.Added.HashString, false, nil
+	}
+	return "", false, errors.New("torrent-add: missing torrent in response")
+}
+
+// Exists returns true when a torrent with the info hash exists.
+func (cl *Client) Exists(ctx context.Context, infoHash string) (bool, error) {
+	t, err := cl.Torrent(ctx, infoHash)
+	return t != nil, err
+}
+
+// Torrent retrieves the torrent with the info hash using torrent-get.
+// Returns nil when the torrent does not exist.
+func (cl *Client) Torrent(ctx context.Context, infoHash string) (*Torrent, error) {
+	torrents, err := cl.torrents(ctx, strings.ToLower(infoHash))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range torrents {
+		if strings.EqualFold(t.HashString, infoHash) {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// List returns the client's torrents.
+func (cl *Client) List(ctx context.Context) ([]clients.Torrent, error) {
+	res, err := cl.torrents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	torrents := make([]clients.Torrent, 0, len(res))
+	for _, t := range res {
+		torrents = append(torrents, clients.Torrent{
+			InfoHash: strings.ToLower(t.HashString),
+			Name:     t.Name,
+			Complete: t.PercentDone >= 1,
+			Active:   t.Status != statusStopped,
+		})
+	}
+	return torrents, nil
+}
+
+// statusStopped is the status of a stopped torrent.
+const statusStopped = 0
+
+// torrents retrieves the torrents with the info hashes using torrent-get, or
+// all torrents when no info hashes are provided.
+func (cl *Client) torrents(ctx context.Context, infoHashes ...string) ([]Torrent, error) {
+	var res struct {
+		Torrents []Torrent `json:"torrents"`
+	}
+	args := map[string]interface{}{
+		"fields": []string{"id", "hashString", "name", "downloadDir", "labels", "status", "percentDone"},
+	}
+	if len(infoHashes) != 0 {
+		args["ids"] = infoHashes
+	}
+	if err := cl.Do(ctx, "torrent-get", args, &res); err != nil {
+		return nil, err
+	}
+	return res.Torrents, nil
+}
+
+// Do executes the rpc method with the arguments, decoding the response's
+// arguments into result. The session id handshake is handled transparently.
+func (cl *Client) Do(ctx context.Context, method string, args, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"method":    method,
+		"arguments": args,
+	})
+	if err != nil {
+		return err
+	}
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for retry := true; ; retry = false {
+		req, err := http.NewRequestWithContext(ctx, "POST", cl.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cl.sessionID != "" {
+			req.Header.Set(sessionHeader, cl.sessionID)
+		}
+		if cl.Username != "" || cl.Password != "" {
+			req.SetBasicAuth(cl.Username, cl.Password)
+		}
+		res, err := cl.cl.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		buf, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		switch {
+		case res.StatusCode == http.StatusConflict && retry:
+			cl.sessionID = res.Header.Get(sessionHeader)
+			continue
+		case res.StatusCode == http.StatusUnauthorized:
+			return fmt.Errorf("%s: invalid username or password", method)
+		case res.StatusCode != http.StatusOK:
+			return fmt.Errorf("%s: invalid http status %d", method, res.StatusCode)
+		}
+		var v struct {
+			Result    string          `json:"result"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(buf, &v); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		if v.Result != "success" {
+			return fmt.Errorf("%s: %s", method, v.Result)
+		}
+		if result == nil || len(v.Arguments) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(v.Arguments, result); err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,170 @@
+package transmission
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/moistari/bhdapi/bhdtest"
+	"github.com/moistari/bhdapi/clients"
+)
+
+func TestAdd(t *testing.T) {
+	srv := newServer("user", "pass")
+	defer srv.Close()
+	cl := New(srv.URL+"/transmission/rpc", WithCredentials("user", "pass"))
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999", 38702381297)
+	ctx := context.Background()
+	opts := clients.AddOptions{
+		Category: "movies",
+		Tags:     []string{"bhd"},
+		SavePath: "/data/movies",
+		Paused:   true,
+	}
+	for i, expExists := range []bool{false, true} {
+		infoHash, exists, err := cl.Add(ctx, buf, opts)
+		switch {
+		case err != nil:
+			t.Fatalf("test %d expected no error, got: %v", i, err)
+		case infoHash != exp:
+			t.Errorf("test %d expected info hash %s, got: %s", i, exp, infoHash)
+		case exists != expExists:
+			t.Errorf("test %d expected exists %t, got: %t", i, expExists, exists)
+		}
+	}
+	torrent, err := cl.Torrent(ctx, exp)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case torrent == nil:
+		t.Fatalf("expected torrent %s", exp)
+	case torrent.DownloadDir != "/data/movies" || len(torrent.Labels) != 2 || torrent.Labels[0] != "movies" || torrent.Labels[1] != "bhd" || torrent.Status != 0:
+		t.Errorf("expected torrent options to be set, got: %+v", torrent)
+	}
+	torrents, err := cl.List(ctx)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(torrents) != 1 || torrents[0].InfoHash != exp || torrents[0].Active || torrents[0].Complete:
+		t.Errorf("expected stopped incomplete torrent %s, got: %+v", exp, torrents)
+	}
+	if exists, err := cl.Exists(ctx, "0000000000000000000000000000000000000000"); err != nil || exists {
+		t.Errorf("expected torrent to not exist, got: %t %v", exists, err)
+	}
+	if srv.handshakes != 1 {
+		t.Errorf("expected 1 session handshake, got: %d", srv.handshakes)
+	}
+	if _, err := New(srv.URL+"/transmission/rpc").Exists(ctx, exp); err == nil {
+		t.Errorf("expected error without credentials")
+	}
+}
+
+// server is a fake Transmission rpc server.
+type server struct {
+	*httptest.Server
+	username, password string
+	mu                 sync.Mutex
+	handshakes         int
+	torrents           []map[string]interface{}
+}
+
+// newServer creates a fake Transmission rpc server.
+func newServer(username, password string) *server {
+	s := &server{
+		username: username,
+		password: password,
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if username, password, _ := req.BasicAuth(); req.URL.Path != "/transmission/rpc" || username != s.username || password != s.password {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if req.Header.Get(sessionHeader) != "session" {
+		s.handshakes++
+		w.Header().Set(sessionHeader, "session")
+		http.Error(w, "Conflict", http.StatusConflict)
+		return
+	}
+	var v struct {
+		Method    string `json:"method"`
+		Arguments struct {
+			Metainfo    string   `json:"metainfo"`
+			DownloadDir string   `json:"download-dir"`
+			Labels      []string `json:"labels"`
+			Paused      bool     `json:"paused"`
+			IDs         []string `json:"ids"`
+		} `json:"arguments"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	args := make(map[string]interface{})
+	switch v.Method {
+	case "torrent-add":
+		buf, err := base64.StdEncoding.DecodeString(v.Arguments.Metainfo)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		infoHash, err := clients.InfoHash(buf)
+		if err != nil {
+			writeJSON(w, "invalid or corrupt torrent file", nil)
+			return
+		}
+		for _, t := range s.torrents {
+			if t["hashString"] == infoHash {
+				args["torrent-duplicate"] = t
+			}
+		}
+		if args["torrent-duplicate"] == nil {
+			t := map[string]interface{}{
+				"id":          len(s.torrents) + 1,
+				"hashString":  infoHash,
+				"name":        "torrent",
+				"downloadDir": v.Arguments.DownloadDir,
+				"labels":      v.Arguments.Labels,
+				"status":      map[bool]int{true: 0, false: 4}[v.Arguments.Paused],
+			}
+			s.torrents = append(s.torrents, t)
+			args["torrent-added"] = t
+		}
+	case "torrent-get":
+		torrents := []map[string]interface{}{}
+		for _, t := range s.torrents {
+			if v.Arguments.IDs == nil {
+				torrents = append(torrents, t)
+			}
+			for _, id := range v.Arguments.IDs {
+				if t["hashString"] == id {
+					torrents = append(torrents, t)
+				}
+			}
+		}
+		args["torrents"] = torrents
+	default:
+		writeJSON(w, "method name not recognized", nil)
+		return
+	}
+	writeJSON(w, "success", args)
+}
+
+// writeJSON writes a rpc response.
+func writeJSON(w http.ResponseWriter, result string, args map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"result":    result,
+		"arguments": args,
+	})
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+)
+
+// shells are the shells with completion scripts.
+var shells = []string{"bash", "zsh", "fish"}
+
+// flagValues are the values completed for flags.
+var flagValues = map[string][]string{
+	"categories": bhdapi.Categories,
+	"types":      bhdapi.Types,
+	"sources":    bhdapi.Sources,
+	"genres":     bhdapi.Genres,
+	"groups":     bhdapi.Groups,
+	"features":   bhdapi.Features,
+	"sort":       bhdapi.SortFields,
+	"order":      bhdapi.Orders,
+	"format":     formats,
+	"color":      colors,
+	"columns":    columnNames(),
+	"client":     torrentClients,
+}
+
+// completionCmd is the completion command.
+func completionCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must supply one of: " + strings.Join(shells, ", "))
+		}
+		cmds := completionCmds()
+		switch args[0] {
+		case "bash":
+			return bashCompletion(w, cmds)
+		case "zsh":
+			return zshCompletion(w, cmds)
+		case "fish":
+			return fishCompletion(w, cmds)
+		}
+		return fmt.Errorf("invalid shell %q (must be one of: %s)", args[0], strings.Join(shells, ", "))
+	}
+}
+
+// compCmd is a command's completion definition.
+type compCmd struct {
+	name  string
+	desc  string
+	flags []compFlag
+	args  []string
+}
+
+// compFlag is a flag's completion definition.
+type compFlag struct {
+	name   string
+	usage  string
+	bool   bool
+	list   bool
+	values []string
+}
+
+// completionCmds returns the completion definitions for the commands, built
+// from the commands' flag definitions.
+func completionCmds() []compCmd {
+	var cmds []compCmd
+	for _, c := range commands() {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.flags(fs)
+		cmd := compCmd{name: c.name, desc: c.desc}
+		fs.VisitAll(func(f *flag.Flag) {
+			_, usage := flag.UnquoteUsage(f)
+			cf := compFlag{
+				name:   f.Name,
+				usage:  usage,
+				values: flagValues[f.Name],
+				list:   f.Name == "columns",
+			}
+			if v, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && v.IsBoolFlag() {
+				cf.bool = true
+			}
+			if v, ok := f.Value.(fieldValue); ok && v.v.Kind() == reflect.Slice {
+				cf.list = true
+			}
+			switch {
+			case c.name == "profile" && f.Name == "format":
+				cf.values = profileFormats
+			case c.name == "reconcile" && f.Name == "format":
+				cf.values = reconcileFormats
+			case c.name == "upgrades" && f.Name == "format":
+				cf.values = upgradesFormats
+			case c.name == "score" && f.Name == "format":
+				cf.values = scoreFormats
+			case c.name == "titles" && f.Name == "format":
+				cf.values = titlesFormats
+			}
+			cmd.flags = append(cmd.flags, cf)
+		})
+		switch c.name {
+		case "profile":
+			cmd.args = profileActions
+		case "completion":
+			cmd.args = shells
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// subcommands returns the names of the non-default commands.
+func subcommands(cmds []compCmd) []string {
+	var names []string
+	for _, cmd := range cmds[1:] {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+// bashCompletion writes the bash completion script.
+func bashCompletion(w io.Writer, cmds []compCmd) error {
+	b := new(strings.Builder)
+	b.WriteString("# bash completion for bhdsearch\n")
+	b.WriteString("# generated by: bhdsearch completion bash\n\n")
+	b.WriteString("_bhdsearch_values() {\n")
+	b.WriteString("\tlocal IFS=$'\\n' prefix=\"\" word=\"$cur\" i\n")
+	b.WriteString("\tif [[ -n \"$2\" && \"$cur\" == *,* ]]; then\n")
+	b.WriteString("\t\tprefix=\"${cur%,*},\"\n")
+	b.WriteString("\t\tword=\"${cur##*,}\"\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("\tCOMPREPLY=($(compgen -W \"$1\" -- \"$word\"))\n")
+	b.WriteString("\tfor i in \"${!COMPREPLY[@]}\"; do\n")
+	b.WriteString("\t\tCOMPREPLY[i]=\"$prefix$(printf '%q' \"${COMPREPLY[i]}\")\"\n")
+	b.WriteString("\tdone\n")
+	b.WriteString("}\n\n")
+	b.WriteString("_bhdsearch() {\n")
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\" cmd=\"\" i\n")
+	b.WriteString("\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("\t\tcase \"${COMP_WORDS[i]}\" in\n")
+	fmt.Fprintf(b, "\t\t%s)\n\t\t\tcmd=\"${COMP_WORDS[i]}\"\n\t\t\tbreak\n\t\t\t;;\n", strings.Join(subcommands(cmds), "|"))
+	b.WriteString("\t\tesac\n")
+	b.WriteString("\tdone\n")
+	// flag values, per command, as flags of the same name may complete
+	// different values
+	b.WriteString("\tcase \"$cmd\" in\n")
+	for _, cmd := range cmds {
+		var cases []string
+		var valueFlags []string
+		for _, f := range cmd.flags {
+			switch {
+			case len(f.values) != 0:
+				list := ""
+				if f.list {
+					list = "1"
+				}
+				cases = append(cases, fmt.Sprintf("\t\t-%s | --%s)\n\t\t\t_bhdsearch_values %s %q\n\t\t\treturn\n\t\t\t;;\n", f.name, f.name, bashQuote(f.values), list))
+			case !f.bool:
+				valueFlags = append(valueFlags, "-"+f.name+" | --"+f.name)
+			}
+		}
+		if len(valueFlags) != 0 {
+			sort.Strings(valueFlags)
+			cases = append(cases, fmt.Sprintf("\t\t%s)\n\t\t\treturn\n\t\t\t;;\n", strings.Join(valueFlags, " | ")))
+		}
+		if len(cases) == 0 {
+			continue
+		}
+		name := cmd.name
+		if name == "" {
+			name = "\"\""
+		}
+		fmt.Fprintf(b, "\t%s)\n\t\tcase \"$prev\" in\n%s\t\tesac\n\t\t;;\n", name, strings.Join(cases, ""))
+	}
+	b.WriteString("\tesac\n")
+	// flags
+	b.WriteString("\tif [[ \"$cur\" == -* ]]; then\n")
+	b.WriteString("\t\tcase \"$cmd\" in\n")
+	for _, cmd := range cmds {
+		var names []string
+		for _, f := range cmd.flags {
+			names = append(names, "--"+f.name)
+		}
+		name := cmd.name
+		if name == "" {
+			name = "\"\""
+		}
+		fmt.Fprintf(b, "\t\t%s)\n\t\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\t\t;;\n", name, strings.Join(names, " "))
+	}
+	b.WriteString("\t\tesac\n")
+	b.WriteString("\t\treturn\n")
+	b.WriteString("\tfi\n")
+	// args
+	b.WriteString("\tcase \"$cmd\" in\n")
+	fmt.Fprintf(b, "\t\"\")\n\t\tif ((COMP_CWORD == 1)); then\n\t\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\tfi\n\t\t;;\n", strings.Join(subcommands(cmds), " "))
+	for _, cmd := range cmds {
+		if len(cmd.args) != 0 {
+			fmt.Fprintf(b, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n\t\t;;\n", cmd.name, strings.Join(cmd.args, " "))
+		}
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("complete -o default -F _bhdsearch bhdsearch\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshCompletion writes the zsh completion script.
+func zshCompletion(w io.Writer, cmds []compCmd) error {
+	b := new(strings.Builder)
+	b.WriteString("#compdef bhdsearch\n")
+	b.WriteString("# zsh completion for bhdsearch\n")
+	b.WriteString("# generated by: bhdsearch completion zsh\n\n")
+	b.WriteString("_bhdsearch() {\n")
+	b.WriteString("\tlocal cmd=\"\" i\n")
+	b.WriteString("\tfor ((i = 2; i < CURRENT; i++)); do\n")
+	b.WriteString("\t\tcase \"${words[i]}\" in\n")
+	fmt.Fprintf(b, "\t\t%s)\n\t\t\tcmd=\"${words[i]}\"\n\t\t\tbreak\n\t\t\t;;\n", strings.Join(subcommands(cmds), "|"))
+	b.WriteString("\t\tesac\n")
+	b.WriteString("\tdone\n")
+	b.WriteString("\tif [[ -n \"$cmd\" ]]; then\n")
+	b.WriteString("\t\tshift $((i - 1)) words\n")
+	b.WriteString("\t\t((CURRENT -= i - 1))\n")
+	b.WriteString("\tfi\n")
+	b.WriteString("\tcase \"$cmd\" in\n")
+	for _, cmd := range cmds {
+		name := cmd.name
+		if name == "" {
+			name = "\"\""
+		}
+		fmt.Fprintf(b, "\t%s)\n\t\t_arguments", name)
+		for _, f := range cmd.flags {
+			for _, prefix := range []string{"-", "--"} {
+				spec := prefix + f.name
+				if !f.bool {
+					spec += "="
+				}
+				spec += "[" + zshEscape(f.usage) + "]"
+				switch {
+				case f.bool:
+				case len(f.values) != 0 && f.list:
+					spec += ":" + f.name + ":_values -s , " + f.name + " " + zshValues(f.values)
+				case len(f.values) != 0:
+					spec += ":" + f.name + ":(" + zshValues(f.values) + ")"
+				default:
+					spec += ":" + f.name + ":_files"
+				}
+				fmt.Fprintf(b, " \\\n\t\t\t%s", shQuote(spec))
+			}
+		}
+		switch {
+		case cmd.name == "":
+			fmt.Fprintf(b, " \\\n\t\t\t%s", shQuote("1:command:(("+zshCommands(cmds)+"))"))
+		case len(cmd.args) != 0:
+			fmt.Fprintf(b, " \\\n\t\t\t%s", shQuote("1:"+cmd.name+":("+zshValues(cmd.args)+")"))
+		}
+		b.WriteString("\n\t\t;;\n")
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n\n")
+	b.WriteString("compdef _bhdsearch bhdsearch\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fishCompletion writes the fish completion script.
+func fishCompletion(w io.Writer, cmds []compCmd) error {
+	b := new(strings.Builder)
+	b.WriteString("# fish completion for bhdsearch\n")
+	b.WriteString("# generated by: bhdsearch completion fish\n\n")
+	b.WriteString("function __bhdsearch_list\n")
+	b.WriteString("\tset -l prefix (string replace -r '[^,]*$' '' -- (commandline -ct))\n")
+	b.WriteString("\tfor v in $argv\n")
+	b.WriteString("\t\tprintf '%s%s\\n' $prefix $v\n")
+	b.WriteString("\tend\n")
+	b.WriteString("end\n\n")
+	b.WriteString("complete -c bhdsearch -f\n")
+	names := strings.Join(subcommands(cmds), " ")
+	for _, cmd := range cmds[1:] {
+		fmt.Fprintf(b, "complete -c bhdsearch -n '__fish_use_subcommand' -a %s -d %s\n", cmd.name, shQuote(cmd.desc))
+	}
+	for _, cmd := range cmds {
+		cond := "not __fish_seen_subcommand_from " + names
+		if cmd.name != "" {
+			cond = "__fish_seen_subcommand_from " + cmd.name
+		}
+		for _, f := range cmd.flags {
+			fmt.Fprintf(b, "complete -c bhdsearch -n %s -l %s", shQuote(cond), f.name)
+			switch {
+			case f.bool:
+			case len(f.values) != 0 && f.list:
+				fmt.Fprintf(b, " -x -a %s", shQuote("(__bhdsearch_list "+fishValues(f.values)+")"))
+			case len(f.values) != 0:
+				fmt.Fprintf(b, " -x -a %s", shQuote(fishValues(f.values)))
+			default:
+				b.WriteString(" -r -F")
+			}
+			fmt.Fprintf(b, " -d %s\n", shQuote(f.usage))
+		}
+		if len(cmd.args) != 0 {
+			fmt.Fprintf(b, "complete -c bhdsearch -n %s -a %s\n", shQuote(cond), shQuote(strings.Join(cmd.args, " ")))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// bashQuote quotes the values as a newline separated bash $” string.
+func bashQuote(values []string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	v := make([]string, len(values))
+	for i, s := range values {
+		v[i] = r.Replace(s)
+	}
+	return "$'" + strings.Join(v, `\n`) + "'"
+}
+
+// shQuote single quotes s for a posix shell.
+func shQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscape escapes s for use in a zsh _arguments description.
+func zshEscape(s string) string {
+	return strings.NewReplacer(`[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
+
+// zshValues returns the values as a zsh word list, escaping spaces.
+func zshValues(values []string) string {
+	r := strings.NewReplacer(` `, `\ `, `:`, `\:`, `(`, `\(`, `)`, `\)`)
+	v := make([]string, len(values))
+	for i, s := range values {
+		v[i] = r.Replace(s)
+	}
+	return strings.Join(v, " ")
+}
+
+// zshCommands returns the subcommands as a zsh value:description list.
+func zshCommands(cmds []compCmd) string {
+	var v []string
+	for _, cmd := range cmds[1:] {
+		v = append(v, cmd.name+`\:`+`"`+cmd.desc+`"`)
+	}
+	return strings.Join(v, " ")
+}
+
+// fishValues returns the values as double quoted fish words.
+func fishValues(values []string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	v := make([]string, len(values))
+	for i, s := range values {
+		v[i] = `"` + r.Replace(s) + `"`
+	}
+	return strings.Join(v, " ")
+}
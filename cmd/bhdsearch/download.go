@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/moistari/bhdapi"
+)
+
+// defaultName is the default torrent file name template.
+const defaultName = `{{if .FolderName}}{{.FolderName}}{{else}}{{.ID}}{{end}}.torrent`
+
+// downloadCmd is the download command.
+func downloadCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	req := bhdapi.Search()
+	dir := fs.String("dir", ".", "output `directory`")
+	name := fs.String("name", defaultName, "torrent file name `template`")
+	concurrency := fs.Int("concurrency", 4, "number of concurrent downloads")
+	dryRun := fs.Bool("dry-run", false, "resolve and print torrents without downloading")
+	watch := fs.Bool("watch", false, "write torrents to the torrent client watch directory instead of --dir")
+	watchDir := fs.String("watch-dir", "", "torrent client watch `directory` used with --watch (default: watch_dir from config file)")
+	all := fs.Bool("all", false, "download all pages of search results")
+	limit := fs.Int("limit", 0, "maximum number of search results to download (0 = no limit)")
+	loadSearch := profileFlag(fs, req)
+	filters := searchFlags(fs, req)
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		req, err := loadSearch()
+		if err != nil {
+			return err
+		}
+		t, err := template.New("").Funcs(funcs).Parse(*name)
+		if err != nil {
+			return err
+		}
+		out := *dir
+		if *watch {
+			if out = *watchDir; out == "" {
+				config, err := bhdapi.LoadConfig()
+				if err != nil {
+					return err
+				}
+				if out = config["watch_dir"]; out == "" {
+					return errors.New("must supply --watch-dir or set watch_dir in the config file")
+				}
+			}
+		}
+		// determine if a saved search or any search filters were set
+		var search bool
+		fs.Visit(func(f *flag.Flag) {
+			for _, name := range append(filters, "profile") {
+				search = search || f.Name == name
+			}
+		})
+		cl := newClient()
+		torrents, err := resolve(ctx, cl, req, args, search, *all, *limit)
+		if err != nil {
+			return err
+		}
+		var mu sync.Mutex
+		var errs []error
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, max(*concurrency, 1))
+		for _, torrent := range torrents {
+			buf := new(bytes.Buffer)
+			if err := t.Execute(buf, torrent); err != nil {
+				return err
+			}
+			path := filepath.Join(out, sanitize(buf.String()))
+			if *dryRun {
+				fmt.Fprintf(w, "%d: %s\n", torrent.ID, path)
+				continue
+			}
+			wg.Add(1)
+			go func(torrent bhdapi.Torrent) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				err := download(ctx, cl, torrent.ID, path)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("torrent %d: %w", torrent.ID, err))
+					return
+				}
+				fmt.Fprintf(w, "%d: %s\n", torrent.ID, path)
+			}(torrent)
+		}
+		wg.Wait()
+		return errors.Join(errs...)
+	}
+}
+
+// resolve resolves the args to torrents. Args are torrent ids (as id:N), info
+// hashes, or search query terms (see bhdapi.ParseQuery). When any query words
+// are provided or search is true, the search request is used to find torrents.
+func resolve(ctx context.Context, cl *bhdapi.Client, req *bhdapi.SearchRequest, args []string, search, all bool, limit int) ([]bhdapi.Torrent, error) {
+	var torrents []bhdapi.Torrent
+	var query []string
+	for _, arg := range args {
+		switch {
+		case idRE.MatchString(arg):
+			id, _ := strconv.Atoi(idRE.FindStringSubmatch(arg)[1])
+			torrents = append(torrents, bhdapi.Torrent{ID: id})
+		case infoHashRE.MatchString(arg):
+			res, err := bhdapi.Search().WithInfoHash(strings.ToLower(arg)).Do(ctx, cl)
+			if err != nil {
+				return nil, err
+			}
+			if len(res.Results) == 0 {
+				return nil, fmt.Errorf("no torrent found with info hash %s", arg)
+			}
+			torrents = append(torrents, res.Results[0])
+		default:
+			query = append(query, arg)
+		}
+	}
+	if len(query) != 0 {
+		if err := applyQuery(req, query); err != nil {
+			return nil, err
+		}
+		search = true
+	}
+	if !search {
+		if len(torrents) == 0 {
+			return nil, errors.New("must supply torrent ids (id:N), info hashes, or a search query")
+		}
+		return torrents, nil
+	}
+	err := results(ctx, cl, req, all, limit, func(torrent bhdapi.Torrent) error {
+		torrents = append(torrents, torrent)
+		return nil
+	})
+	return torrents, err
+}
+
+// download downloads the torrent to the path.
+func download(ctx context.Context, cl *bhdapi.Client, id int, path string) error {
+	buf, err := cl.Torrent(ctx, id)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, buf)
+}
+
+// writeFile atomically writes buf to the named file.
+func writeFile(name string, buf []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), ".bhd-*.torrent")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// sanitize sanitizes a file name.
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+}
+
+// idRE matches torrent ids, as id:N.
+var idRE = regexp.MustCompile(`^id:([0-9]+)$`)
+
+// infoHashRE matches info hashes.
+var infoHashRE = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/clients"
+	"github.com/moistari/bhdapi/clients/deluge"
+	"github.com/moistari/bhdapi/clients/qbittorrent"
+	"github.com/moistari/bhdapi/clients/rtorrent"
+	"github.com/moistari/bhdapi/clients/transmission"
+)
+
+// newClient creates the client. Keys are loaded using bhdapi.LoadCredentials,
+// and are not accepted as flags, so that they are not visible in shell
+// history or process listings.
+func newClient() *bhdapi.Client {
+	return bhdapi.New(bhdapi.WithCredentialsFrom(bhdapi.LoadCredentials))
+}
+
+// torrentClients are the supported torrent clients.
+var torrentClients = []string{"qbittorrent", "transmission", "deluge", "rtorrent"}
+
+// torrentClientFlags registers the torrent client flags on fs, returning a
+// func that creates the torrent client. Unset flags default to the client,
+// client_url and client_username config file values. The password is only
+// read from the config file's client_password or client_password_command
+// values, so that it is not visible in shell history or process listings.
+func torrentClientFlags(fs *flag.FlagSet) func(context.Context) (clients.TorrentClient, error) {
+	name := fs.String("client", "", "torrent client `name` ("+strings.Join(torrentClients, ", ")+") (default: client from config file)")
+	urlstr := fs.String("client-url", "", "torrent client `url` (default: client_url from config file)")
+	username := fs.String("client-username", "", "torrent client `username` (default: client_username from config file)")
+	return func(ctx context.Context) (clients.TorrentClient, error) {
+		config, err := bhdapi.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range map[string]*string{
+			"client":          name,
+			"client_url":      urlstr,
+			"client_username": username,
+		} {
+			if *v == "" {
+				*v = config[k]
+			}
+		}
+		password, err := config.Value(ctx, "client_password")
+		if err != nil {
+			return nil, err
+		}
+		if *urlstr == "" {
+			return nil, errors.New("must supply --client-url or set client_url in the config file")
+		}
+		switch *name {
+		case "qbittorrent":
+			return qbittorrent.New(*urlstr, qbittorrent.WithCredentials(*username, password)), nil
+		case "transmission":
+			return transmission.New(*urlstr, transmission.WithCredentials(*username, password)), nil
+		case "deluge":
+			return deluge.New(*urlstr, deluge.WithPassword(password)), nil
+		case "rtorrent":
+			return rtorrent.New(*urlstr, rtorrent.WithCredentials(*username, password)), nil
+		case "":
+			return nil, errors.New("must supply --client or set client in the config file")
+		}
+		return nil, fmt.Errorf("invalid torrent client %q (must be one of: %s)", *name, strings.Join(torrentClients, ", "))
+	}
+}
+
+// searchFlags registers a flag on fs for each json tagged field of the search
+// request, returning the flag names. Flag names are the json tag with
+// underscores replaced by dashes.
+func searchFlags(fs *flag.FlagSet, req *bhdapi.SearchRequest) []string {
+	var names []string
+	v := reflect.ValueOf(req).Elem()
+	typ := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		name := flagName(typ.Field(i))
+		if name == "" {
+			continue
+		}
+		fs.Var(fieldValue{v.Field(i)}, name, usage(typ.Field(i)))
+		names = append(names, name)
+	}
+	return names
+}
+
+// flagName returns the flag name for the field, or "" when the field is not
+// json tagged.
+func flagName(f reflect.StructField) string {
+	tag := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if tag == "" || tag == "-" {
+		return ""
+	}
+	return strings.ReplaceAll(tag, "_", "-")
+}
+
+// profileFlag registers the saved search flag on fs, returning a func that
+// returns the search request to use. When a saved search is named, it is
+// loaded and the search flags set on the command line override its fields.
+func profileFlag(fs *flag.FlagSet, req *bhdapi.SearchRequest) func() (*bhdapi.SearchRequest, error) {
+	profile := fs.String("profile", "", "saved search `name` to run (see: bhdsearch profile list)")
+	return func() (*bhdapi.SearchRequest, error) {
+		if *profile == "" {
+			return req, nil
+		}
+		saved, err := bhdapi.LoadSearch(*profile)
+		if err != nil {
+			return nil, err
+		}
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) {
+			set[f.Name] = true
+		})
+		v, src := reflect.ValueOf(saved).Elem(), reflect.ValueOf(req).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if name := flagName(v.Type().Field(i)); name != "" && set[name] {
+				v.Field(i).Set(src.Field(i))
+			}
+		}
+		return saved, nil
+	}
+}
+
+// applyQuery parses the args as a bhdapi.ParseQuery query, and applies the
+// parsed fields to the search request. Free text is appended to the search
+// request's query, and list values are appended to the search request's
+// lists.
+func applyQuery(req *bhdapi.SearchRequest, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	q, err := bhdapi.ParseQuery(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	v, src := reflect.ValueOf(req).Elem(), reflect.ValueOf(q).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f, field := src.Field(i), v.Type().Field(i)
+		switch {
+		case !field.IsExported() || f.IsZero() || field.Name == "Page" && q.Page == 1:
+		case field.Name == "Search":
+			req.Search = strings.TrimSpace(req.Search + " " + q.Search)
+		case f.Kind() == reflect.Slice:
+			v.Field(i).Set(reflect.AppendSlice(v.Field(i), f))
+		default:
+			v.Field(i).Set(f)
+		}
+	}
+	return nil
+}
+
+// usage returns the usage for the field.
+func usage(f reflect.StructField) string {
+	tag := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if s, ok := usages[tag]; ok {
+		return s
+	}
+	switch f.Type.Kind() {
+	case reflect.Slice:
+		return "filter by " + tag + " (comma separated `list`)"
+	case reflect.Bool:
+		return "filter by " + tag + " flag"
+	case reflect.String:
+		return "filter by " + tag + " `string`"
+	}
+	return "filter by " + tag + " `int`"
+}
+
+// usages are the usages for fields that are not filters.
+var usages = map[string]string{
+	"search": "search `query` (supports !negative terms)",
+	"sort":   "`field` to sort results by (" + strings.Join(bhdapi.SortFields, ", ") + ")",
+	"order":  "sort `direction` (" + strings.Join(bhdapi.Orders, ", ") + ")",
+	"page":   "first `page` of results",
+}
+
+// fieldValue is a flag.Value for a search request field.
+type fieldValue struct {
+	v reflect.Value
+}
+
+// String satisfies the flag.Value interface.
+func (f fieldValue) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	switch x := f.v.Interface().(type) {
+	case []string:
+		return strings.Join(x, ",")
+	case bhdapi.Bool:
+		if x {
+			return "true"
+		}
+		return ""
+	}
+	if f.v.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+// Set satisfies the flag.Value interface.
+func (f fieldValue) Set(s string) error {
+	switch f.v.Interface().(type) {
+	case []string:
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				f.v.Set(reflect.Append(f.v, reflect.ValueOf(v)))
+			}
+		}
+	case bhdapi.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case string:
+		f.v.SetString(s)
+	case int, int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(i)
+	default:
+		return fmt.Errorf("unsupported type %T", f.v.Interface())
+	}
+	return nil
+}
+
+// IsBoolFlag satisfies the flag package's boolFlag interface.
+func (f fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
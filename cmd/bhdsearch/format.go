@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/metainfo"
+)
+
+// formats are the available output formats.
+var formats = []string{"table", "json", "ndjson", "csv", "template"}
+
+// defaultColumns are the default table and csv columns.
+var defaultColumns = []string{"id", "type", "size", "seeders", "leechers", "created_at", "name", "badges"}
+
+// writer is the interface for result writers.
+type writer interface {
+	Write(bhdapi.Torrent) error
+	Flush() error
+}
+
+// newWriter creates a result writer for the format.
+func newWriter(w io.Writer, format, tmpl string, columns []string, color bool) (writer, error) {
+	for _, col := range columns {
+		if _, ok := torrentFields[col]; !ok && col != "badges" {
+			return nil, fmt.Errorf("invalid column %q", col)
+		}
+	}
+	switch format {
+	case "table":
+		if len(columns) == 0 {
+			columns = defaultColumns
+		}
+		return &tableWriter{w: w, columns: columns, color: color}, nil
+	case "json", "ndjson":
+		return &jsonWriter{w: w, columns: columns, nd: format == "ndjson"}, nil
+	case "csv":
+		if len(columns) == 0 {
+			columns = defaultColumns
+		}
+		return &csvWriter{w: csv.NewWriter(w), columns: columns}, nil
+	case "template":
+		if tmpl == "" {
+			return nil, fmt.Errorf("must supply --template with --format template")
+		}
+		t, err := template.New("").Funcs(funcs).Parse(tmpl)
+		if err != nil {
+			return nil, err
+		}
+		return &templateWriter{w: w, t: t}, nil
+	}
+	return nil, fmt.Errorf("invalid format %q (must be one of %s)", format, strings.Join(formats, ", "))
+}
+
+// tableWriter writes results as an aligned table. Rows are buffered until
+// flushed, and cells are padded by their uncolored width, as tabwriter would
+// count color escapes as part of the cell width.
+type tableWriter struct {
+	w       io.Writer
+	columns []string
+	color   bool
+	rows    [][]string
+	colored [][]string
+}
+
+// Write satisfies the writer interface.
+func (w *tableWriter) Write(t bhdapi.Torrent) error {
+	if len(w.rows) == 0 {
+		header := make([]string, len(w.columns))
+		for i, col := range w.columns {
+			header[i] = strings.ToUpper(col)
+		}
+		w.rows, w.colored = append(w.rows, header), append(w.colored, header)
+	}
+	v, c := make([]string, len(w.columns)), make([]string, len(w.columns))
+	for i, col := range w.columns {
+		switch col {
+		case "badges":
+			v[i], c[i] = badges(t, false), badges(t, w.color)
+			continue
+		case "size":
+			v[i] = metainfo.FormatSize(t.Size)
+		case "bumped_at", "created_at":
+			v[i] = age(field(t, col).Interface().(bhdapi.Time))
+		default:
+			v[i] = value(t, col)
+		}
+		c[i] = v[i]
+	}
+	w.rows, w.colored = append(w.rows, v), append(w.colored, c)
+	return nil
+}
+
+// Flush satisfies the writer interface.
+func (w *tableWriter) Flush() error {
+	widths := make([]int, len(w.columns))
+	for _, row := range w.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	for i, row := range w.rows {
+		var b strings.Builder
+		for j, cell := range w.colored[i] {
+			b.WriteString(cell)
+			if j != len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(row[j])+2))
+			}
+		}
+		if _, err := fmt.Fprintln(w.w, b.String()); err != nil {
+			return err
+		}
+	}
+	w.rows, w.colored = nil, nil
+	return nil
+}
+
+// jsonWriter writes results as a json array or as newline delimited json.
+type jsonWriter struct {
+	w       io.Writer
+	columns []string
+	nd      bool
+	n       int
+}
+
+// Write satisfies the writer interface.
+func (w *jsonWriter) Write(t bhdapi.Torrent) error {
+	var v interface{} = t
+	if len(w.columns) != 0 {
+		m := make(map[string]interface{}, len(w.columns))
+		for _, col := range w.columns {
+			if col == "badges" {
+				m[col] = badges(t, false)
+			} else {
+				m[col] = field(t, col).Interface()
+			}
+		}
+		v = m
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var prefix, suffix string
+	switch {
+	case w.nd:
+		suffix = "\n"
+	case w.n == 0:
+		prefix = "[\n  "
+	default:
+		prefix = ",\n  "
+	}
+	w.n++
+	_, err = fmt.Fprintf(w.w, "%s%s%s", prefix, buf, suffix)
+	return err
+}
+
+// Flush satisfies the writer interface.
+func (w *jsonWriter) Flush() error {
+	var err error
+	switch {
+	case w.nd:
+	case w.n == 0:
+		_, err = fmt.Fprintln(w.w, "[]")
+	default:
+		_, err = fmt.Fprintln(w.w, "\n]")
+	}
+	return err
+}
+
+// csvWriter writes results as csv.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	n       int
+}
+
+// Write satisfies the writer interface.
+func (w *csvWriter) Write(t bhdapi.Torrent) error {
+	if w.n == 0 {
+		if err := w.w.Write(w.columns); err != nil {
+			return err
+		}
+	}
+	w.n++
+	v := make([]string, len(w.columns))
+	for i, col := range w.columns {
+		if col == "badges" {
+			v[i] = badges(t, false)
+		} else {
+			v[i] = value(t, col)
+		}
+	}
+	return w.w.Write(v)
+}
+
+// Flush satisfies the writer interface.
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// templateWriter writes results using a template.
+type templateWriter struct {
+	w io.Writer
+	t *template.Template
+}
+
+// Write satisfies the writer interface.
+func (w *templateWriter) Write(t bhdapi.Torrent) error {
+	if err := w.t.Execute(w.w, t); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w.w)
+	return err
+}
+
+// Flush satisfies the writer interface.
+func (w *templateWriter) Flush() error {
+	return nil
+}
+
+// magnetWriter writes results as magnet links.
+type magnetWriter struct {
+	w io.Writer
+	// announce, when not nil, retrieves the announce url added to the magnet
+	// links, using the first result's torrent id.
+	announce func(id int) (string, error)
+	trackers []string
+}
+
+// Write satisfies the writer interface.
+func (w *magnetWriter) Write(t bhdapi.Torrent) error {
+	if w.announce != nil {
+		announce, err := w.announce(t.ID)
+		if err != nil {
+			return err
+		}
+		w.announce, w.trackers = nil, []string{announce}
+	}
+	_, err := fmt.Fprintln(w.w, t.Magnet(w.trackers...))
+	return err
+}
+
+// Flush satisfies the writer interface.
+func (w *magnetWriter) Flush() error {
+	return nil
+}
+
+// funcs are the template funcs.
+var funcs = template.FuncMap{
+	"size":   metainfo.FormatSize,
+	"age":    age,
+	"badges": func(t bhdapi.Torrent) string { return badges(t, false) },
+	"magnet": func(t bhdapi.Torrent) string { return t.Magnet() },
+}
+
+// torrentFields are the torrent field indexes by json tag.
+var torrentFields = func() map[string]int {
+	m := make(map[string]int)
+	typ := reflect.TypeOf(bhdapi.Torrent{})
+	for i := 0; i < typ.NumField(); i++ {
+		if tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]; tag != "" && tag != "-" {
+			m[tag] = i
+		}
+	}
+	return m
+}()
+
+// columnNames returns the available column names.
+func columnNames() []string {
+	names := make([]string, 0, len(torrentFields)+1)
+	typ := reflect.TypeOf(bhdapi.Torrent{})
+	for i := 0; i < typ.NumField(); i++ {
+		if tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]; tag != "" && tag != "-" {
+			names = append(names, tag)
+		}
+	}
+	return append(names, "badges")
+}
+
+// field returns the torrent field for the column.
+func field(t bhdapi.Torrent, col string) reflect.Value {
+	return reflect.ValueOf(t).Field(torrentFields[col])
+}
+
+// value returns the torrent field value for the column as a string.
+func value(t bhdapi.Torrent, col string) string {
+	switch x := field(t, col).Interface().(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bhdapi.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.String()
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// age formats the time as a short age relative to now.
+func age(t bhdapi.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := time.Since(t.Time)
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo ago", int(d.Hours()/24/30))
+	}
+	return fmt.Sprintf("%dy ago", int(d.Hours()/24/365))
+}
+
+// badges returns the promo and feature badges for the torrent, optionally
+// colored with ansi escapes.
+func badges(t bhdapi.Torrent, color bool) string {
+	var v []string
+	add := func(ok bhdapi.Bool, s, code string) {
+		switch {
+		case !bool(ok):
+		case color:
+			v = append(v, "\x1b["+code+"m"+s+"\x1b[0m")
+		default:
+			v = append(v, s)
+		}
+	}
+	add(t.Freeleech, "FL", "1;32")
+	add(t.Promo75, "75%", "32")
+	add(t.Promo50, "50%", "33")
+	add(t.Promo25, "25%", "33")
+	add(t.Refund, "Refund", "36")
+	add(t.Rewind, "Rewind", "36")
+	add(t.Rescue, "Rescue", "36")
+	add(t.Limited, "Limited", "31")
+	add(t.DV, "DV", "1;35")
+	add(t.HDR10P, "HDR10+", "1;34")
+	add(t.HDR10, "HDR10", "34")
+	add(t.HLG, "HLG", "34")
+	add(t.Internal, "Internal", "1;37")
+	return strings.Join(v, " ")
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/moistari/bhdapi"
+)
+
+func TestWriters(t *testing.T) {
+	torrents := []bhdapi.Torrent{
+		{ID: 7531, Type: "BD Remux", Size: 38702381297, Seeders: 112, Name: "Fight Club 1999 BluRay 1080p DTS-HD MA 5.1 AVC REMUX-FraMeSToR", Internal: true},
+		{ID: 101233, Type: "UHD Remux", Size: 71263782400, Seeders: 241, Name: "Fight Club 1999 UHD BluRay 2160p TrueHD Atmos 7.1 DV HEVC REMUX-FraMeSToR", Freeleech: true, DV: true},
+		{ID: 150012, Type: "1080p", Size: 12884901888, Seeders: 87, Name: "Fight Club 1999 1080p BluRay DD+ 5.1 x264-BHDStudio"},
+	}
+	tests := []struct {
+		name    string
+		format  string
+		columns []string
+		color   bool
+		exp     string
+	}{
+		{
+			"table", "table", []string{"id", "badges", "size", "name"}, false,
+			`ID      BADGES    SIZE      NAME
+7531    Internal  36.0 GiB  Fight Club 1999 BluRay 1080p DTS-HD MA 5.1 AVC REMUX-FraMeSToR
+101233  FL DV     66.4 GiB  Fight Club 1999 UHD BluRay 2160p TrueHD Atmos 7.1 DV HEVC REMUX-FraMeSToR
+150012            12.0 GiB  Fight Club 1999 1080p BluRay DD+ 5.1 x264-BHDStudio
+`,
+		},
+		{
+			"table color", "table", []string{"id", "badges", "size"}, true,
+			"ID      BADGES    SIZE\n" +
+				"7531    \x1b[1;37mInternal\x1b[0m  36.0 GiB\n" +
+				"101233  \x1b[1;32mFL\x1b[0m \x1b[1;35mDV\x1b[0m     66.4 GiB\n" +
+				"150012            12.0 GiB\n",
+		},
+		{
+			"csv", "csv", []string{"id", "type", "size", "badges", "name"}, true,
+			`id,type,size,badges,name
+7531,BD Remux,38702381297,Internal,Fight Club 1999 BluRay 1080p DTS-HD MA 5.1 AVC REMUX-FraMeSToR
+101233,UHD Remux,71263782400,FL DV,Fight Club 1999 UHD BluRay 2160p TrueHD Atmos 7.1 DV HEVC REMUX-FraMeSToR
+150012,1080p,12884901888,,Fight Club 1999 1080p BluRay DD+ 5.1 x264-BHDStudio
+`,
+		},
+		{
+			"json", "json", []string{"id", "badges", "seeders"}, true,
+			`[
+  {"badges":"Internal","id":7531,"seeders":112},
+  {"badges":"FL DV","id":101233,"seeders":241},
+  {"badges":"","id":150012,"seeders":87}
+]
+`,
+		},
+		{
+			"ndjson", "ndjson", []string{"id", "badges"}, false,
+			`{"badges":"Internal","id":7531}
+{"badges":"FL DV","id":101233}
+{"badges":"","id":150012}
+`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := newWriter(&buf, test.format, "", test.columns, test.color)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			for _, torrent := range torrents {
+				if err := w.Write(torrent); err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if s := buf.String(); s != test.exp {
+				t.Errorf("expected:\n%s\ngot:\n%s", test.exp, s)
+			}
+		})
+	}
+}
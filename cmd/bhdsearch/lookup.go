@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/moistari/bhdapi/metainfo"
+)
+
+// lookupCmd is the lookup command.
+func lookupCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	pieceLength := fs.Int64("piece-length", 0, "piece length in `bytes` (0 = the typical bhd piece length for the content size)")
+	concurrency := fs.Int("concurrency", 0, "number of pieces hashed concurrently (0 = number of cpus)")
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if len(args) == 0 {
+			return errors.New("must supply a file or directory")
+		}
+		cl := newClient()
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, name := range args {
+			buf, err := metainfo.Create(ctx, name, metainfo.CreateOptions{
+				PieceLength: *pieceLength,
+				Concurrency: *concurrency,
+			})
+			if err != nil {
+				return err
+			}
+			m, err := metainfo.Parse(buf)
+			if err != nil {
+				return err
+			}
+			res, err := cl.Lookup(ctx, m)
+			if err != nil {
+				return err
+			}
+			if len(res.Results) == 0 {
+				fmt.Fprintf(tw, "%s\t%s\tnone\t\t\n", name, res.InfoHash)
+			}
+			// inexact matches only match the folder name and size
+			status := "name+size"
+			if res.Exact {
+				status = "exact"
+			}
+			for _, t := range res.Results {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", name, res.InfoHash, status, t.ID, t.Name)
+			}
+		}
+		return tw.Flush()
+	}
+}
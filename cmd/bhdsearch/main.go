@@ -1,37 +1,208 @@
+// Command bhdsearch searches bhd and downloads torrents.
+//
+// Usage:
+//
+//	bhdsearch [flags] [query...]
+//	bhdsearch download [flags] [id:N|info_hash|query...]
+//	bhdsearch tui [flags] [query...]
+//	bhdsearch profile list|show|delete [name...]
+//	bhdsearch reconcile [flags]
+//	bhdsearch lookup [flags] path...
+//	bhdsearch upgrades [flags] library.csv
+//	bhdsearch score [flags] [query...]
+//	bhdsearch titles [flags] [query...]
+//	bhdsearch completion bash|zsh|fish
+//
+// Query args use the bhdapi.ParseQuery syntax:
+//
+//	bhdsearch 'fight club type:"BD Remux" year:1995..2005 imdb>=7 freeleech !cam'
 package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"sort"
-	"strconv"
+	"strings"
 
 	"github.com/moistari/bhdapi"
 )
 
 func main() {
-	apikey := flag.String("apikey", "", "api key")
-	rsskey := flag.String("rsskey", "", "rss key")
-	flag.Parse()
-	if err := run(context.Background(), *apikey, *rsskey, flag.Args()...); err != nil {
+	if err := run(context.Background(), os.Stdout, os.Args[1:]); err != nil && !errors.Is(err, flag.ErrHelp) {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run(ctx context.Context, apikey, rsskey string, args ...string) error {
-	cl := bhdapi.New(bhdapi.WithApiKey(apikey), bhdapi.WithRssKey(rsskey))
-	res, err := cl.Search(ctx, args...)
-	if err != nil {
+// command is a bhdsearch command.
+type command struct {
+	name  string
+	usage string
+	desc  string
+	// flags registers the command's flags, returning the func that runs the
+	// command with the remaining args.
+	flags func(fs *flag.FlagSet) func(ctx context.Context, w io.Writer, args []string) error
+}
+
+// commands returns the bhdsearch commands. The first command is the default
+// command.
+func commands() []command {
+	return []command{
+		{"", "[flags] [query...]", "search torrents", searchCmd},
+		{"download", "[flags] [id:N|info_hash|query...]", "download torrents", downloadCmd},
+		{"tui", "[flags] [query...]", "browse search results interactively", tuiCmd},
+		{"profile", "[flags] list|show|delete [name...]", "manage saved searches", profileCmd},
+		{"reconcile", "[flags]", "compare seeding status with a torrent client", reconcileCmd},
+		{"lookup", "[flags] path...", "find torrents matching local content", lookupCmd},
+		{"upgrades", "[flags] library.csv", "find releases that outrank a library's releases", upgradesCmd},
+		{"score", "[flags] [query...]", "score and rank search results", scoreCmd},
+		{"titles", "[flags] [query...]", "group search results by title", titlesCmd},
+		{"completion", "bash|zsh|fish", "generate a shell completion script", completionCmd},
+	}
+}
+
+// run runs the command named by the first arg, or the default command.
+func run(ctx context.Context, w io.Writer, args []string) error {
+	cmds := commands()
+	cmd := cmds[0]
+	for _, c := range cmds[1:] {
+		if len(args) != 0 && args[0] == c.name {
+			cmd, args = c, args[1:]
+		}
+	}
+	name := strings.TrimSpace("bhdsearch " + cmd.name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	f := cmd.flags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s\n", name, cmd.usage)
+		if cmd.name == "" {
+			for _, c := range cmds[1:] {
+				fmt.Fprintf(fs.Output(), "       bhdsearch %s %s\n", c.name, c.usage)
+			}
+		}
+		fmt.Fprintf(fs.Output(), "\nflags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	sort.Slice(res.Results, func(i, j int) bool {
-		return res.Results[i].ID < res.Results[j].ID
-	})
-	for _, r := range res.Results {
-		fmt.Fprintf(os.Stdout, "%02d: %s %q %s\n", r.ID, r.InfoHash[:7], r.Name, "https://beyond-hd.me/torrents/a."+strconv.Itoa(r.ID))
+	return f(ctx, w, fs.Args())
+}
+
+// searchCmd is the search command.
+func searchCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	req := bhdapi.Search()
+	all := fs.Bool("all", false, "retrieve all pages of results")
+	limit := fs.Int("limit", 0, "maximum number of results (0 = no limit)")
+	format := fs.String("format", "table", "output `format` ("+strings.Join(formats, ", ")+")")
+	tmpl := fs.String("template", "", "go `template` used with --format template (example: '{{.Name}} {{size .Size}}')")
+	columns := fs.String("columns", "", "comma separated `list` of columns for table, csv and json output ("+strings.Join(columnNames(), ", ")+")")
+	color := fs.String("color", "auto", "colorize table output ("+strings.Join(colors, ", ")+")")
+	save := fs.String("save", "", "save the search as `name` instead of running it")
+	magnet := fs.Bool("magnet", false, "print magnet links instead of results")
+	announce := fs.Bool("announce", false, "add the personal announce url to magnet links (downloads the first result's torrent file)")
+	loadSearch := profileFlag(fs, req)
+	searchFlags(fs, req)
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if *announce && !*magnet {
+			return errors.New("--announce can only be used with --magnet")
+		}
+		if *magnet {
+			var err error
+			fs.Visit(func(f *flag.Flag) {
+				if f.Name == "format" || f.Name == "columns" || f.Name == "template" {
+					err = fmt.Errorf("--magnet cannot be used with --%s", f.Name)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		req, err := loadSearch()
+		if err != nil {
+			return err
+		}
+		if err := applyQuery(req, args); err != nil {
+			return err
+		}
+		if *save != "" {
+			if err := bhdapi.SaveSearch(*save, req); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "saved search %s\n", *save)
+			return nil
+		}
+		var cols []string
+		if *columns != "" {
+			cols = strings.Split(*columns, ",")
+		}
+		cl := newClient()
+		var out writer
+		if *magnet {
+			mw := &magnetWriter{w: w}
+			if *announce {
+				mw.announce = func(id int) (string, error) {
+					return cl.AnnounceURL(ctx, id)
+				}
+			}
+			out = mw
+		} else if out, err = newWriter(w, *format, *tmpl, cols, useColor(*color, w)); err != nil {
+			return err
+		}
+		if err := results(ctx, cl, req, *all, *limit, out.Write); err != nil {
+			return err
+		}
+		return out.Flush()
+	}
+}
+
+// results calls f with each search result, up to limit results (0 = no
+// limit). Only the first page is requested, unless all is true.
+func results(ctx context.Context, cl *bhdapi.Client, req *bhdapi.SearchRequest, all bool, limit int, f func(bhdapi.Torrent) error) error {
+	if !all {
+		res, err := req.Do(ctx, cl)
+		if err != nil {
+			return err
+		}
+		for i, torrent := range res.Results {
+			if limit != 0 && i >= limit {
+				break
+			}
+			if err := f(torrent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for n := 0; (limit == 0 || n < limit) && req.Next(ctx, cl); n++ {
+		if err := f(req.Cur()); err != nil {
+			return err
+		}
+	}
+	return req.Err()
+}
+
+// colors are the color options.
+var colors = []string{"auto", "always", "never"}
+
+// useColor returns true when color output should be used.
+func useColor(color string, w io.Writer) bool {
+	switch color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
 	}
-	return nil
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/bhdtest"
+)
+
+func TestFlags(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	saved := bhdapi.Search("fight club").WithTypes("BD Remux").WithSources("Blu-ray")
+	saved.Sort = "seeders"
+	if err := bhdapi.SaveSearch("fc", saved); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		exp  *bhdapi.SearchRequest
+	}{
+		{
+			"flags override profile",
+			[]string{"--profile", "fc", "--types", "1080p", "--order", "asc"},
+			&bhdapi.SearchRequest{Search: "fight club", Types: []string{"1080p"}, Sources: []string{"Blu-ray"}, Sort: "seeders", Order: "asc", Page: 1},
+		},
+		{
+			"query merged with flags",
+			[]string{"--types", "1080p", "--search", "fight", "club", "source:Blu-ray", "type:720p"},
+			&bhdapi.SearchRequest{Search: "fight club", Types: []string{"1080p", "720p"}, Sources: []string{"Blu-ray"}, Page: 1},
+		},
+		{
+			"query merged with profile and flags",
+			[]string{"--profile", "fc", "--sort", "size", "remux", "group:FraMeSToR"},
+			&bhdapi.SearchRequest{Search: "fight club remux", Types: []string{"BD Remux"}, Sources: []string{"Blu-ray"}, Groups: []string{"FraMeSToR"}, Sort: "size", Page: 1},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			args := append([]string{"--save", "out"}, test.args...)
+			if err := run(context.Background(), &buf, args); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			req, err := bhdapi.LoadSearch("out")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			exp, _ := bhdapi.MarshalSearch(test.exp, "json")
+			if v, _ := bhdapi.MarshalSearch(req, "json"); !bytes.Equal(v, exp) {
+				t.Errorf("expected:\n%s\ngot:\n%s", exp, v)
+			}
+		})
+	}
+	for _, args := range [][]string{
+		{"--announce", "fight", "club"},
+		{"--magnet", "--format", "json", "fight", "club"},
+	} {
+		if err := run(context.Background(), new(bytes.Buffer), args); err == nil {
+			t.Errorf("expected usage error for %v", args)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := bhdapi.New(bhdapi.WithApiKey(srv.ApiKey), bhdapi.WithTransport(srv.Transport()))
+	tests := []struct {
+		name     string
+		args     []string
+		exp      []int
+		searches int
+	}{
+		{"id", []string{"id:7531"}, []int{7531}, 0},
+		{"ids", []string{"id:7531", "id:101233"}, []int{7531, 101233}, 0},
+		{"single match", []string{"severance"}, []int{180777}, 1},
+		{"several matches", []string{"fight", "club"}, []int{150012, 101233, 7531}, 1},
+		{"id and query", []string{"id:180777", "matrix", "type:720p"}, []int{180777, 170555}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := srv.Requests("search")
+			torrents, err := resolve(context.Background(), cl, bhdapi.Search(), test.args, false, false, 0)
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			var ids []int
+			for _, torrent := range torrents {
+				ids = append(ids, torrent.ID)
+			}
+			if !slices.Equal(ids, test.exp) {
+				t.Errorf("expected %v, got: %v", test.exp, ids)
+			}
+			if n := srv.Requests("search") - n; n != test.searches {
+				t.Errorf("expected %d search requests, got: %d", test.searches, n)
+			}
+		})
+	}
+	if _, err := resolve(context.Background(), cl, bhdapi.Search(), nil, false, false, 0); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	cmds := commands()
+	var names []string
+	for _, c := range cmds[1:] {
+		names = append(names, c.name)
+	}
+	flags := make(map[string][]string)
+	for _, c := range cmds {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.flags(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			flags[c.name] = append(flags[c.name], f.Name)
+		})
+	}
+	tests := []struct {
+		shell string
+		// cmd returns the expected text for the subcommand.
+		cmd func(name string) string
+		// flag returns true when the section completes the command's flag.
+		flag func(section, cmd, name string) bool
+		// section returns the command's part of the script.
+		section func(script, cmd string) (string, bool)
+	}{
+		{
+			"bash",
+			func(name string) string { return "\t\t" + strings.Join(names, "|") + ")\n" },
+			func(section, _, name string) bool { return slices.Contains(strings.Fields(section), "--"+name) },
+			func(script, cmd string) (string, bool) {
+				if cmd == "" {
+					cmd = `""`
+				}
+				_, after, ok := strings.Cut(script, "\t\t"+cmd+")\n\t\t\tCOMPREPLY=($(compgen -W \"")
+				section, _, _ := strings.Cut(after, "\"")
+				return section, ok
+			},
+		},
+		{
+			"zsh",
+			func(name string) string { return "\t" + name + ")\n\t\t_arguments" },
+			func(section, _, name string) bool { return strings.Contains(section, "\t'--"+name) },
+			func(script, cmd string) (string, bool) {
+				if cmd == "" {
+					cmd = `""`
+				}
+				_, after, ok := strings.Cut(script, "\t"+cmd+")\n\t\t_arguments")
+				section, _, _ := strings.Cut(after, "\n\t\t;;\n")
+				return section, ok
+			},
+		},
+		{
+			"fish",
+			func(name string) string { return "-n '__fish_use_subcommand' -a " + name + " -d " },
+			func(section, cmd, name string) bool {
+				cond := "not __fish_seen_subcommand_from " + strings.Join(names, " ")
+				if cmd != "" {
+					cond = "__fish_seen_subcommand_from " + cmd
+				}
+				return strings.Contains(section, "complete -c bhdsearch -n '"+cond+"' -l "+name+" ")
+			},
+			func(script, _ string) (string, bool) { return script, true },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := run(context.Background(), &buf, []string{"completion", test.shell}); err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			script := buf.String()
+			for _, name := range names {
+				if !strings.Contains(script, test.cmd(name)) {
+					t.Errorf("expected script to complete command %s", name)
+				}
+			}
+			for _, c := range cmds {
+				section, ok := test.section(script, c.name)
+				if !ok {
+					t.Fatalf("expected script to complete command %q flags", c.name)
+				}
+				for _, name := range flags[c.name] {
+					if !test.flag(section, c.name, name) {
+						t.Errorf("expected script to complete command %q flag --%s", c.name, name)
+					}
+				}
+			}
+			// check the syntax when the shell is installed
+			if _, err := exec.LookPath(test.shell); err != nil {
+				return
+			}
+			cmd := exec.Command(test.shell, "-n")
+			cmd.Stdin = strings.NewReader(script)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("expected valid %s script, got: %v\n%s", test.shell, err, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+)
+
+// profileActions are the profile command actions.
+var profileActions = []string{"list", "show", "delete"}
+
+// profileFormats are the saved search output formats.
+var profileFormats = []string{"yaml", "json"}
+
+// profileCmd is the profile command.
+func profileCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	format := fs.String("format", "yaml", "show output `format` ("+strings.Join(profileFormats, ", ")+")")
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if len(args) == 0 {
+			return errors.New("must supply one of: " + strings.Join(profileActions, ", "))
+		}
+		action, names := args[0], args[1:]
+		switch action {
+		case "list":
+			names, err := bhdapi.ListSearches()
+			if err != nil {
+				return err
+			}
+			for _, name := range names {
+				fmt.Fprintln(w, name)
+			}
+			return nil
+		case "show", "delete":
+			if len(names) == 0 {
+				return fmt.Errorf("%s: must supply a saved search name", action)
+			}
+		default:
+			return fmt.Errorf("invalid action %q (must be one of: %s)", action, strings.Join(profileActions, ", "))
+		}
+		for _, name := range names {
+			if action == "delete" {
+				if err := bhdapi.DeleteSearch(name); err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "deleted search %s\n", name)
+				continue
+			}
+			req, err := bhdapi.LoadSearch(name)
+			if err != nil {
+				return err
+			}
+			buf, err := bhdapi.MarshalSearch(req, *format)
+			if err != nil {
+				return err
+			}
+			if len(names) > 1 {
+				fmt.Fprintf(w, "# %s\n", name)
+			}
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
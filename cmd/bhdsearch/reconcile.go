@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moistari/bhdapi/clients"
+)
+
+// reconcileFormats are the reconcile command output formats.
+var reconcileFormats = []string{"text", "json"}
+
+// reconcileCmd is the reconcile command.
+func reconcileCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	newTorrentClient := torrentClientFlags(fs)
+	format := fs.String("format", "text", "output `format` ("+strings.Join(reconcileFormats, ", ")+")")
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("unexpected args: %s", strings.Join(args, " "))
+		}
+		tc, err := newTorrentClient(ctx)
+		if err != nil {
+			return err
+		}
+		report, err := clients.Reconcile(ctx, newClient(), tc)
+		if err != nil {
+			return err
+		}
+		switch *format {
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		case "text":
+		default:
+			return fmt.Errorf("invalid format %q (must be one of: %s)", *format, strings.Join(reconcileFormats, ", "))
+		}
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, section := range []struct {
+			status     string
+			mismatches []clients.Mismatch
+		}{
+			{"missing", report.Missing},
+			{"unannounced", report.Unannounced},
+			{"stopped", report.Stopped},
+		} {
+			for _, m := range section.mismatches {
+				status := section.status
+				if section.status == "stopped" && m.Client == nil {
+					status = "removed"
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", status, m.Torrent.ID, m.Torrent.InfoHash, m.Torrent.Name)
+			}
+		}
+		return tw.Flush()
+	}
+}
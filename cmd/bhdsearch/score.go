@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/quality"
+)
+
+// scoreFormats are the score command output formats.
+var scoreFormats = []string{"text", "json"}
+
+// scoreCmd is the score command.
+func scoreCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	req := bhdapi.Search()
+	all := fs.Bool("all", false, "score all pages of results")
+	limit := fs.Int("limit", 0, "maximum number of results scored (0 = no limit)")
+	profile := fs.String("quality", "", "quality profile `file` (yaml or json) (default: built in profile)")
+	best := fs.Bool("best", false, "only show the top scored result for each title")
+	explain := fs.Bool("explain", false, "show the reasons for each score")
+	format := fs.String("format", "text", "output `format` ("+strings.Join(scoreFormats, ", ")+")")
+	loadSearch := profileFlag(fs, req)
+	searchFlags(fs, req)
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if !slices.Contains(scoreFormats, *format) {
+			return fmt.Errorf("invalid format %q (must be one of: %s)", *format, strings.Join(scoreFormats, ", "))
+		}
+		p := quality.DefaultProfile()
+		if *profile != "" {
+			var err error
+			if p, err = quality.ReadProfile(*profile); err != nil {
+				return err
+			}
+		}
+		req, err := loadSearch()
+		if err != nil {
+			return err
+		}
+		if err := applyQuery(req, args); err != nil {
+			return err
+		}
+		cl := newClient()
+		var torrents []bhdapi.Torrent
+		if err := results(ctx, cl, req, *all, *limit, func(torrent bhdapi.Torrent) error {
+			torrents = append(torrents, torrent)
+			return nil
+		}); err != nil {
+			return err
+		}
+		var scores []quality.Score
+		if *best {
+			scores = p.Best(torrents)
+		} else {
+			scores = p.Sort(torrents)
+		}
+		if *format == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(scores)
+		}
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+		for _, s := range scores {
+			fmt.Fprintf(tw, "%s\t%d\t %s\n", strconv.FormatFloat(s.Total, 'f', -1, 64), s.Torrent.ID, s.Torrent.Name)
+			if *explain {
+				fmt.Fprintf(tw, "\t\t %s\n", s.Explain())
+			}
+		}
+		return tw.Flush()
+	}
+}
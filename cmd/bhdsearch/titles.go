@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/metainfo"
+)
+
+// titlesFormats are the titles command output formats.
+var titlesFormats = []string{"text", "json"}
+
+// titlesCmd is the titles command.
+func titlesCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	req := bhdapi.Search()
+	all := fs.Bool("all", false, "group all pages of results")
+	limit := fs.Int("limit", 0, "maximum number of results grouped (0 = no limit)")
+	summary := fs.Bool("summary", false, "only show titles, editions and release sets, without torrents")
+	format := fs.String("format", "text", "output `format` ("+strings.Join(titlesFormats, ", ")+")")
+	loadSearch := profileFlag(fs, req)
+	searchFlags(fs, req)
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if !slices.Contains(titlesFormats, *format) {
+			return fmt.Errorf("invalid format %q (must be one of: %s)", *format, strings.Join(titlesFormats, ", "))
+		}
+		req, err := loadSearch()
+		if err != nil {
+			return err
+		}
+		if err := applyQuery(req, args); err != nil {
+			return err
+		}
+		cl := newClient()
+		var torrents []bhdapi.Torrent
+		if err := results(ctx, cl, req, *all, *limit, func(torrent bhdapi.Torrent) error {
+			torrents = append(torrents, torrent)
+			return nil
+		}); err != nil {
+			return err
+		}
+		titles := bhdapi.Titles(torrents)
+		if *format == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(titles)
+		}
+		for _, title := range titles {
+			name := title.Name
+			if title.Year != 0 {
+				name += fmt.Sprintf(" (%d)", title.Year)
+			}
+			if title.ImdbID != "" {
+				name += " " + title.ImdbID
+			}
+			fmt.Fprintf(w, "%s  %s\n", name, stats(title.Stats))
+			for _, e := range title.Editions {
+				res := e.Resolution
+				if res == "" {
+					res = "unknown"
+				}
+				fmt.Fprintf(w, "  %s  %s\n", res, stats(e.Stats))
+				for _, s := range e.Sets {
+					fmt.Fprintf(w, "    %s  %s\n", s.Type, stats(s.Stats))
+					if *summary {
+						continue
+					}
+					for _, t := range s.Torrents {
+						fmt.Fprintf(w, "      %d  %s  %d  %s\n", t.ID, metainfo.FormatSize(t.Size), t.Seeders, t.Name)
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// stats formats the aggregates of a group of torrents.
+func stats(s bhdapi.Stats) string {
+	v := []string{
+		fmt.Sprintf("%d %s", s.Count, plural(s.Count, "release", "releases")),
+		fmt.Sprintf("%d %s", s.Seeders, plural(s.Seeders, "seeder", "seeders")),
+	}
+	if s.MinSize == s.MaxSize {
+		v = append(v, metainfo.FormatSize(s.MaxSize))
+	} else {
+		v = append(v, metainfo.FormatSize(s.MinSize)+"-"+metainfo.FormatSize(s.MaxSize))
+	}
+	if len(s.Features) != 0 {
+		v = append(v, strings.Join(s.Features, " "))
+	}
+	return strings.Join(v, ", ")
+}
+
+// plural returns one when n is 1, otherwise other.
+func plural(n int, one, other string) string {
+	if n == 1 {
+		return one
+	}
+	return other
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/metainfo"
+	"golang.org/x/term"
+)
+
+// tuiCmd is the tui command.
+func tuiCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	req := bhdapi.Search()
+	dir := fs.String("dir", ".", "download `directory`")
+	loadSearch := profileFlag(fs, req)
+	searchFlags(fs, req)
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		req, err := loadSearch()
+		if err != nil {
+			return err
+		}
+		if err := applyQuery(req, args); err != nil {
+			return err
+		}
+		return runTUI(ctx, w, newClient(), req, *dir)
+	}
+}
+
+// runTUI runs the tui.
+func runTUI(ctx context.Context, w io.Writer, cl *bhdapi.Client, req *bhdapi.SearchRequest, dir string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("tui requires a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+	// alternate screen, hide cursor
+	fmt.Fprint(w, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(w, "\x1b[?25h\x1b[?1049l")
+	ui := &tui{
+		ctx:     ctx,
+		cl:      cl,
+		w:       w,
+		dir:     dir,
+		base:    req,
+		typ:     -1,
+		source:  -1,
+		feature: -1,
+		sort:    -1,
+		size: func() (int, int) {
+			width, height, err := term.GetSize(fd)
+			if err != nil {
+				return 80, 24
+			}
+			return width, height
+		},
+	}
+	return ui.run(os.Stdin)
+}
+
+// tui is an interactive terminal ui for browsing search results.
+type tui struct {
+	ctx  context.Context
+	cl   *bhdapi.Client
+	w    io.Writer
+	dir  string
+	size func() (int, int)
+
+	// base is the search request built from the command line.
+	base *bhdapi.SearchRequest
+	// req is the current search request, with toggled filters applied.
+	req      *bhdapi.SearchRequest
+	torrents []bhdapi.Torrent
+	done     bool
+	err      error
+
+	cur, off int
+	detail   bool
+	status   string
+
+	// toggled filters, -1 when not set
+	freeleech bool
+	typ       int
+	source    int
+	feature   int
+	sort      int
+	asc       bool
+}
+
+// run runs the ui, reading keys from r until quit.
+func (ui *tui) run(r io.Reader) error {
+	ui.reset()
+	buf := make([]byte, 16)
+	for {
+		ui.render()
+		n, err := r.Read(buf)
+		if err != nil {
+			return err
+		}
+		ui.status = ""
+		_, rows := ui.rows()
+		switch key := string(buf[:n]); key {
+		case "q", "\x03", "\x1b":
+			return nil
+		case "j", "\x1b[B", "\x0e":
+			ui.move(1)
+		case "k", "\x1b[A", "\x10":
+			ui.move(-1)
+		case " ", "\x1b[6~", "\x06":
+			ui.move(rows)
+		case "b", "\x1b[5~", "\x02":
+			ui.move(-rows)
+		case "g", "\x1b[H":
+			ui.move(-len(ui.torrents))
+		case "G", "\x1b[F":
+			ui.load(-1)
+			ui.move(len(ui.torrents))
+		case "\r", "\n":
+			ui.detail = !ui.detail
+			ui.move(0)
+		case "f":
+			ui.freeleech = !ui.freeleech
+			ui.reset()
+		case "t":
+			ui.typ = cycle(ui.typ, len(bhdapi.Types))
+			ui.reset()
+		case "s":
+			ui.source = cycle(ui.source, len(bhdapi.Sources))
+			ui.reset()
+		case "v":
+			ui.feature = cycle(ui.feature, len(bhdapi.Features))
+			ui.reset()
+		case "o":
+			ui.sort = cycle(ui.sort, len(bhdapi.SortFields))
+			ui.reset()
+		case "r":
+			ui.asc = !ui.asc
+			ui.reset()
+		case "d":
+			ui.download()
+		}
+	}
+}
+
+// reset resets the search request using the base request and the toggled
+// filters, and loads the first results.
+func (ui *tui) reset() {
+	req := ui.base.WithPage(ui.base.Page)
+	if ui.freeleech {
+		req = req.WithFreeleech(true)
+	}
+	if ui.typ != -1 {
+		req = req.WithTypes(bhdapi.Types[ui.typ])
+	}
+	if ui.source != -1 {
+		req = req.WithSources(bhdapi.Sources[ui.source])
+	}
+	if ui.feature != -1 {
+		req = req.WithFeatures(bhdapi.Features[ui.feature])
+	}
+	if ui.sort != -1 {
+		req = req.WithSort(bhdapi.SortFields[ui.sort])
+	}
+	if ui.asc {
+		req = req.WithOrder("asc")
+	}
+	ui.req, ui.torrents, ui.done, ui.err = req, nil, false, nil
+	ui.cur, ui.off = 0, 0
+	ui.move(0)
+}
+
+// load loads results until at least n results are loaded, or all results when
+// n is -1. Results are retrieved a page at a time by the request's iterator.
+func (ui *tui) load(n int) {
+	for !ui.done && (n == -1 || len(ui.torrents) < n) {
+		if !ui.req.Next(ui.ctx, ui.cl) {
+			ui.done, ui.err = true, ui.req.Err()
+			break
+		}
+		ui.torrents = append(ui.torrents, ui.req.Cur())
+	}
+}
+
+// move moves the cursor by n rows, loading results as necessary.
+func (ui *tui) move(n int) {
+	_, rows := ui.rows()
+	ui.load(ui.cur + n + rows + 1)
+	ui.cur = max(0, min(ui.cur+n, len(ui.torrents)-1))
+	switch {
+	case ui.cur < ui.off:
+		ui.off = ui.cur
+	case ui.cur >= ui.off+rows:
+		ui.off = ui.cur - rows + 1
+	}
+}
+
+// rows returns the terminal width and the number of list rows.
+func (ui *tui) rows() (int, int) {
+	width, height := ui.size()
+	rows := height - 3
+	if ui.detail {
+		rows = rows / 2
+	}
+	return width, max(rows, 1)
+}
+
+// download downloads the selected torrent.
+func (ui *tui) download() {
+	if len(ui.torrents) == 0 {
+		return
+	}
+	torrent := ui.torrents[ui.cur]
+	name := new(strings.Builder)
+	if err := template.Must(template.New("").Parse(defaultName)).Execute(name, torrent); err != nil {
+		ui.status = "error: " + err.Error()
+		return
+	}
+	path := filepath.Join(ui.dir, sanitize(name.String()))
+	ui.status = fmt.Sprintf("downloading %d...", torrent.ID)
+	ui.render()
+	if err := download(ui.ctx, ui.cl, torrent.ID, path); err != nil {
+		ui.status = "error: " + err.Error()
+		return
+	}
+	ui.status = "downloaded " + path
+}
+
+// render renders the ui.
+func (ui *tui) render() {
+	width, rows := ui.rows()
+	var lines []string
+	// header
+	lines = append(lines, reverse(truncate(ui.header(), width), width))
+	// list
+	for i := ui.off; i < ui.off+rows; i++ {
+		if i >= len(ui.torrents) {
+			lines = append(lines, "")
+			continue
+		}
+		t := ui.torrents[i]
+		line := truncate(fmt.Sprintf("%-7d %-9s %9s %5d %4d  %-s  %s", t.ID, t.Type, metainfo.FormatSize(t.Size), t.Seeders, t.Leechers, badges(t, false), t.Name), width)
+		if i == ui.cur {
+			line = reverse(line, width)
+		}
+		lines = append(lines, line)
+	}
+	// detail
+	if ui.detail && len(ui.torrents) != 0 {
+		lines = append(lines, strings.Repeat("─", width))
+		_, height := ui.size()
+		lines = append(lines, ui.details(width, height-len(lines)-1)...)
+	}
+	// status
+	status := ui.status
+	switch {
+	case status != "":
+	case ui.err != nil:
+		status = "error: " + ui.err.Error()
+	default:
+		status = "j/k move  enter detail  f freeleech  t type  s source  v feature  o sort  r order  d download  q quit"
+	}
+	_, height := ui.size()
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, truncate(status, width))
+	fmt.Fprint(ui.w, "\x1b[H\x1b[2J"+strings.Join(lines, "\r\n"))
+}
+
+// header returns the header line.
+func (ui *tui) header() string {
+	val := func(i int, v []string) string {
+		if i == -1 {
+			return "-"
+		}
+		return v[i]
+	}
+	order := "desc"
+	if ui.asc {
+		order = "asc"
+	}
+	freeleech := "off"
+	if ui.freeleech {
+		freeleech = "on"
+	}
+	loaded := fmt.Sprintf("%d", len(ui.torrents))
+	if !ui.done {
+		loaded += "+"
+	}
+	return fmt.Sprintf(" %q  freeleech:%s  type:%s  source:%s  feature:%s  sort:%s %s  [%d/%s]",
+		ui.req.Search, freeleech, val(ui.typ, bhdapi.Types), val(ui.source, bhdapi.Sources), val(ui.feature, bhdapi.Features),
+		val(ui.sort, bhdapi.SortFields), order, min(ui.cur+1, len(ui.torrents)), loaded)
+}
+
+// details returns the detail lines for the selected torrent, packing fields
+// into at most n lines.
+func (ui *tui) details(width, n int) []string {
+	t := ui.torrents[ui.cur]
+	var lines []string
+	var line string
+	for _, col := range columnNames() {
+		var v string
+		switch col {
+		case "badges":
+			continue
+		case "download_url":
+			v = bhdapi.Redact(t.DownloadURL)
+		default:
+			v = value(t, col)
+		}
+		field := col + ": " + v
+		if line != "" && len(line)+len(field)+3 > width {
+			lines, line = append(lines, line), ""
+		}
+		if line != "" {
+			line += "   "
+		}
+		line += field
+	}
+	lines = append(lines, line)
+	for i := range lines {
+		lines[i] = truncate(lines[i], width)
+	}
+	if len(lines) > n {
+		lines = lines[:max(n, 0)]
+	}
+	return lines
+}
+
+// cycle cycles i through -1 to n-1.
+func cycle(i, n int) int {
+	if i+1 >= n {
+		return -1
+	}
+	return i + 1
+}
+
+// truncate truncates s to the width.
+func truncate(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:max(width-1, 0)]) + "…"
+	}
+	return s
+}
+
+// reverse pads s to the width and renders it in reverse video.
+func reverse(s string, width int) string {
+	if n := len([]rune(s)); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return "\x1b[7m" + s + "\x1b[0m"
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/moistari/bhdapi/quality"
+)
+
+// upgradesFormats are the upgrades command output formats.
+var upgradesFormats = []string{"text", "json"}
+
+// upgradesCmd is the upgrades command.
+func upgradesCmd(fs *flag.FlagSet) func(context.Context, io.Writer, []string) error {
+	profile := fs.String("quality", "", "quality profile `file` (yaml or json) (default: built in profile)")
+	format := fs.String("format", "text", "output `format` ("+strings.Join(upgradesFormats, ", ")+")")
+	return func(ctx context.Context, w io.Writer, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must supply a library csv file (- for stdin)")
+		}
+		if !slices.Contains(upgradesFormats, *format) {
+			return fmt.Errorf("invalid format %q (must be one of: %s)", *format, strings.Join(upgradesFormats, ", "))
+		}
+		p := quality.DefaultProfile()
+		if *profile != "" {
+			var err error
+			if p, err = quality.ReadProfile(*profile); err != nil {
+				return err
+			}
+		}
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		entries, err := quality.ReadLibrary(r)
+		if err != nil {
+			return err
+		}
+		upgrades, err := quality.Upgrades(ctx, newClient(), p, entries)
+		if err != nil {
+			return err
+		}
+		if *format == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(upgrades)
+		}
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		for _, u := range upgrades {
+			title := u.Entry.Title
+			if title == "" {
+				title = u.Entry.ImdbID + u.Entry.TmdbID
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", title, u.Rank, u.Quality)
+			for _, c := range u.Candidates {
+				fmt.Fprintf(tw, "  %d\t%d\t%s\t%s\n", c.Torrent.ID, c.Rank, c.Quality, c.Torrent.Name)
+			}
+		}
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,265 @@
+package bhdapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Credentials are the BHD api and rss keys.
+type Credentials struct {
+	ApiKey string
+	RssKey string
+}
+
+// String satisfies the fmt.Stringer interface. The keys are never included.
+func (c Credentials) String() string {
+	return fmt.Sprintf("Credentials{ApiKey:%s RssKey:%s}", mask(c.ApiKey), mask(c.RssKey))
+}
+
+// GoString satisfies the fmt.GoStringer interface. The keys are never
+// included.
+func (c Credentials) GoString() string {
+	return c.String()
+}
+
+// CredentialsFunc is a func that loads credentials.
+type CredentialsFunc func(context.Context) (*Credentials, error)
+
+// Environment variables read by LoadCredentials.
+const (
+	EnvApiKey = "BHD_API_KEY"
+	EnvRssKey = "BHD_RSS_KEY"
+)
+
+// LoadCredentials loads credentials from the environment and the default
+// config file. Keys set in the environment take precedence over the config
+// file, and their config file commands are not run. A missing config file is
+// not an error.
+func LoadCredentials(ctx context.Context) (*Credentials, error) {
+	creds := &Credentials{
+		ApiKey: os.Getenv(EnvApiKey),
+		RssKey: os.Getenv(EnvRssKey),
+	}
+	if creds.ApiKey != "" && creds.RssKey != "" {
+		return creds, nil
+	}
+	name, err := ConfigFile()
+	if err != nil {
+		return creds, nil
+	}
+	if err := loadCredentialsFile(ctx, name, creds); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// ConfigDir returns the bhdapi config directory
+// ($XDG_CONFIG_HOME/bhdapi).
+func ConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bhdapi"), nil
+}
+
+// ConfigFile returns the path to the default config file
+// ($XDG_CONFIG_HOME/bhdapi/config.toml).
+func ConfigFile() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+// LoadCredentialsFile loads credentials from the named config file.
+//
+// The config file is a flat TOML file:
+//
+//	api_key = "..."
+//	rss_key = "..."
+//
+// Instead of storing the keys in the file, a command can be specified whose
+// first line of output is used as the key:
+//
+//	api_key_command = "pass show bhd/api"
+//	rss_key_command = "pass show bhd/rss"
+func LoadCredentialsFile(ctx context.Context, name string) (*Credentials, error) {
+	creds := new(Credentials)
+	if err := loadCredentialsFile(ctx, name, creds); err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// loadCredentialsFile loads the credentials' unset keys from the named config
+// file.
+func loadCredentialsFile(ctx context.Context, name string, creds *Credentials) error {
+	m, err := ReadConfig(name)
+	if err != nil {
+		return err
+	}
+	for _, k := range []struct {
+		name string
+		v    *string
+	}{
+		{"api_key", &creds.ApiKey},
+		{"rss_key", &creds.RssKey},
+	} {
+		if *k.v != "" {
+			continue
+		}
+		if *k.v, err = m.Value(ctx, k.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Config is a flat config of string values.
+type Config map[string]string
+
+// Value returns the config's value for the key. When the key is not set, and
+// a key_command is set, the command is run with CommandKey and its output
+// returned.
+func (c Config) Value(ctx context.Context, key string) (string, error) {
+	if v := c[key]; v != "" {
+		return v, nil
+	}
+	cmd := c[key+"_command"]
+	if cmd == "" {
+		return "", nil
+	}
+	v, err := CommandKey(ctx, cmd)
+	if err != nil {
+		return "", fmt.Errorf("%s_command: %w", key, err)
+	}
+	return v, nil
+}
+
+// LoadConfig loads the default config file. A missing config file returns an
+// empty config.
+func LoadConfig() (Config, error) {
+	name, err := ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	m, err := ReadConfig(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return Config{}, nil
+	}
+	return m, err
+}
+
+// ReadConfig reads the named config file. See LoadCredentialsFile for the
+// config file format.
+func ReadConfig(name string) (Config, error) {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseConfig(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return m, nil
+}
+
+// CommandKey runs the command using the system shell, returning the first
+// line of its output. The command's output is never included in returned
+// errors, but its error output is.
+func CommandKey(ctx context.Context, command string) (string, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/c"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	buf, err := cmd.Output()
+	if err != nil {
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			return "", fmt.Errorf("command %q failed: %w: %s", command, err, s)
+		}
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	line, _, _ := strings.Cut(string(buf), "\n")
+	key := strings.TrimSpace(line)
+	if key == "" {
+		return "", fmt.Errorf("command %q returned empty output", command)
+	}
+	return key, nil
+}
+
+// parseConfig parses a flat TOML config of string values. Tables are not
+// supported.
+func parseConfig(buf []byte) (Config, error) {
+	m := make(Config)
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			return nil, fmt.Errorf("line %d: tables are not supported", n)
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		end := -1
+		switch {
+		case strings.HasPrefix(v, `"`):
+			for i := 1; i < len(v) && end == -1; i++ {
+				switch v[i] {
+				case '\\':
+					i++
+				case '"':
+					end = i
+				}
+			}
+		case strings.HasPrefix(v, "'"):
+			if i := strings.IndexByte(v[1:], '\''); i != -1 {
+				end = i + 1
+			}
+		default:
+			return nil, fmt.Errorf("line %d: expected string value for %s", n, k)
+		}
+		if end == -1 {
+			return nil, fmt.Errorf("line %d: unterminated string for %s", n, k)
+		}
+		if rest := strings.TrimSpace(v[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected text after string for %s", n, k)
+		}
+		if v[0] == '\'' {
+			v = v[1:end]
+		} else {
+			var err error
+			if v, err = strconv.Unquote(v[:end+1]); err != nil {
+				return nil, fmt.Errorf("line %d: invalid string for %s", n, k)
+			}
+		}
+		m[k] = v
+	}
+	return m, s.Err()
+}
+
+// mask masks a secret value.
+func mask(s string) string {
+	if s == "" {
+		return `""`
+	}
+	return "[redacted]"
+}
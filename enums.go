@@ -0,0 +1,113 @@
+package bhdapi
+
+// Categories are the known search categories.
+var Categories = []string{
+	"Movies",
+	"TV",
+}
+
+// Types are the known search types.
+var Types = []string{
+	"UHD 100",
+	"UHD 66",
+	"UHD 50",
+	"UHD Remux",
+	"BD 50",
+	"BD 25",
+	"BD Remux",
+	"2160p",
+	"1080p",
+	"1080i",
+	"720p",
+	"576p",
+	"540p",
+	"DVD 9",
+	"DVD 5",
+	"DVD Remux",
+	"480p",
+	"Other",
+}
+
+// Sources are the known search sources.
+var Sources = []string{
+	"Blu-ray",
+	"HD-DVD",
+	"WEB",
+	"HDTV",
+	"DVD",
+}
+
+// Genres are the known search genres.
+var Genres = []string{
+	"Action",
+	"Adventure",
+	"Animation",
+	"Anime",
+	"Biography",
+	"Comedy",
+	"Crime",
+	"Documentary",
+	"Drama",
+	"Family",
+	"Fantasy",
+	"Film-Noir",
+	"Game-Show",
+	"History",
+	"Horror",
+	"Kids",
+	"Music",
+	"Musical",
+	"Mystery",
+	"News",
+	"Reality",
+	"Romance",
+	"Sci-Fi",
+	"Short",
+	"Soap",
+	"Sport",
+	"Stand-Up",
+	"Talk-Show",
+	"Thriller",
+	"War",
+	"Western",
+}
+
+// Groups are the known internal release groups.
+var Groups = []string{
+	"FraMeSToR",
+	"BHDStudio",
+	"BeyondHD",
+	"RPG",
+	"iROBOT",
+	"iFT",
+	"ZR",
+	"MKVULTRA",
+}
+
+// Features are the known search features.
+var Features = []string{
+	"DV",
+	"HDR10",
+	"HDR10P",
+	"Commentary",
+}
+
+// SortFields are the known search sort fields.
+var SortFields = []string{
+	"bumped_at",
+	"created_at",
+	"seeders",
+	"leechers",
+	"times_completed",
+	"size",
+	"name",
+	"imdb_rating",
+	"tmdb_rating",
+	"bhd_rating",
+}
+
+// Orders are the known search sort orders.
+var Orders = []string{
+	"asc",
+	"desc",
+}
@@ -3,7 +3,6 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"log"
 
@@ -11,12 +10,10 @@ import (
 )
 
 func main() {
-	api := flag.String("api", "", "api key")
-	rss := flag.String("rss", "", "rss key")
-	flag.Parse()
+	// credentials are read from $BHD_API_KEY/$BHD_RSS_KEY, or from
+	// ~/.config/bhdapi/config.toml
 	cl := bhdapi.New(
-		bhdapi.WithApiKey(*api),
-		bhdapi.WithRssKey(*rss, false),
+		bhdapi.WithCredentialsFrom(bhdapi.LoadCredentials),
 	)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -0,0 +1,116 @@
+//go:build ignore
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// params are the params types and their actions.
+var params = []struct {
+	typ    string
+	action string
+}{
+	{"SearchRequest", "search"},
+}
+
+func main() {
+	dir := flag.String("dir", ".", "package directory")
+	out := flag.String("out", "params_gen.go", "out file")
+	flag.Parse()
+	if err := run(*dir, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, out string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != "gen.go" && fi.Name() != out
+	}, 0)
+	if err != nil {
+		return err
+	}
+	structs := make(map[string]*ast.StructType)
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("// Code generated by gen.go. DO NOT EDIT.\n\n")
+	buf.WriteString("package bhdapi\n\n")
+	buf.WriteString("import \"strings\"\n\n")
+	for _, p := range params {
+		st, ok := structs[p.typ]
+		if !ok {
+			return fmt.Errorf("type %s not found in %s", p.typ, dir)
+		}
+		if err := gen(buf, fset, p.typ, p.action, st); err != nil {
+			return err
+		}
+	}
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(out, b, 0o644)
+}
+
+// gen generates the Action and Encode methods for the struct.
+func gen(buf *bytes.Buffer, fset *token.FileSet, typ, action string, st *ast.StructType) error {
+	fmt.Fprintf(buf, "// Action satisfies the Params interface.\n")
+	fmt.Fprintf(buf, "func (req *%s) Action() string {\n\treturn %q\n}\n\n", typ, action)
+	fmt.Fprintf(buf, "// Encode satisfies the Params interface. Zero value fields are omitted.\n")
+	fmt.Fprintf(buf, "func (req *%s) Encode() map[string]interface{} {\n", typ)
+	buf.WriteString("\tm := make(map[string]interface{})\n")
+	for _, field := range st.Fields.List {
+		if field.Tag == nil || len(field.Names) != 1 {
+			continue
+		}
+		tag, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			return err
+		}
+		key := strings.SplitN(reflect.StructTag(tag).Get("json"), ",", 2)[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		name := field.Names[0].Name
+		expr := new(bytes.Buffer)
+		if err := format.Node(expr, fset, field.Type); err != nil {
+			return err
+		}
+		switch expr.String() {
+		case "string":
+			fmt.Fprintf(buf, "\tif req.%s != \"\" {\n\t\tm[%q] = req.%s\n\t}\n", name, key, name)
+		case "[]string":
+			fmt.Fprintf(buf, "\tif len(req.%s) != 0 {\n\t\tm[%q] = strings.Join(req.%s, \",\")\n\t}\n", name, key, name)
+		case "int", "int64":
+			fmt.Fprintf(buf, "\tif req.%s != 0 {\n\t\tm[%q] = req.%s\n\t}\n", name, key, name)
+		case "Bool":
+			fmt.Fprintf(buf, "\tif req.%s {\n\t\tm[%q] = req.%s.Int()\n\t}\n", name, key, name)
+		default:
+			return fmt.Errorf("%s.%s: unsupported type %s", typ, name, expr)
+		}
+	}
+	buf.WriteString("\treturn m\n}\n\n")
+	return nil
+}
@@ -0,0 +1,44 @@
+package bhdapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// log logs the message and args to the client's logger, if any.
+func (cl *Client) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if cl.Logger != nil {
+		cl.Logger.Log(ctx, level, msg, args...)
+	}
+}
+
+// logStatus logs a warning when the response indicates the client is being
+// throttled.
+func (cl *Client) logStatus(ctx context.Context, res *http.Response, args ...any) {
+	if res.StatusCode == http.StatusTooManyRequests {
+		cl.log(ctx, slog.LevelWarn, "throttled", append(args, "retry_after", res.Header.Get("Retry-After"))...)
+	}
+}
+
+// logDecode logs a warning when a decode error indicates the api response
+// schema has changed.
+func (cl *Client) logDecode(ctx context.Context, err error, args ...any) {
+	if strings.HasPrefix(err.Error(), "json: unknown field") || strings.HasPrefix(err.Error(), "json: cannot unmarshal") {
+		cl.log(ctx, slog.LevelWarn, "schema drift", append(args, "error", cl.Redact(err.Error()))...)
+	}
+}
+
+// redactParams returns a copy of the request params with the rss key
+// redacted.
+func redactParams(m map[string]interface{}) map[string]interface{} {
+	params := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if k == "rsskey" {
+			v = redacted
+		}
+		params[k] = v
+	}
+	return params
+}
@@ -0,0 +1,174 @@
+package metainfo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Decode decodes the bencoded value in buf. Integers are decoded as int64,
+// byte strings as string, lists as []interface{} and dictionaries as
+// map[string]interface{}. Trailing data after the value is an error.
+func Decode(buf []byte) (interface{}, error) {
+	d := &decoder{buf: buf}
+	v, err := d.decode()
+	switch {
+	case err != nil:
+		return nil, err
+	case d.i != len(buf):
+		return nil, fmt.Errorf("bencode: trailing data at offset %d", d.i)
+	}
+	return v, nil
+}
+
+// decoder is a bencode decoder.
+type decoder struct {
+	buf []byte
+	i   int
+	// info is the raw value of the top level dictionary's info key.
+	info []byte
+}
+
+// decode decodes the value at the current offset.
+func (d *decoder) decode() (interface{}, error) {
+	if d.i >= len(d.buf) {
+		return nil, errors.New("bencode: unexpected end of data")
+	}
+	switch c := d.buf[d.i]; {
+	case c == 'i':
+		end := bytes.IndexByte(d.buf[d.i:], 'e')
+		if end == -1 {
+			return nil, fmt.Errorf("bencode: unterminated integer at offset %d", d.i)
+		}
+		s := string(d.buf[d.i+1 : d.i+end])
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || s != strconv.FormatInt(n, 10) || s == "-0" {
+			return nil, fmt.Errorf("bencode: invalid integer %q at offset %d", s, d.i)
+		}
+		d.i += end + 1
+		return n, nil
+	case c >= '0' && c <= '9':
+		return d.str()
+	case c == 'l':
+		d.i++
+		v := []interface{}{}
+		for d.i < len(d.buf) && d.buf[d.i] != 'e' {
+			x, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			v = append(v, x)
+		}
+		if d.i >= len(d.buf) {
+			return nil, errors.New("bencode: unterminated list")
+		}
+		d.i++
+		return v, nil
+	case c == 'd':
+		top := d.i == 0
+		d.i++
+		m := make(map[string]interface{})
+		for d.i < len(d.buf) && d.buf[d.i] != 'e' {
+			key, err := d.str()
+			if err != nil {
+				return nil, err
+			}
+			start := d.i
+			x, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := m[key]; ok {
+				return nil, fmt.Errorf("bencode: duplicate key %q", key)
+			}
+			m[key] = x
+			if top && key == "info" {
+				d.info = d.buf[start:d.i]
+			}
+		}
+		if d.i >= len(d.buf) {
+			return nil, errors.New("bencode: unterminated dictionary")
+		}
+		d.i++
+		return m, nil
+	}
+	return nil, fmt.Errorf("bencode: invalid value at offset %d", d.i)
+}
+
+// str decodes the byte string at the current offset.
+func (d *decoder) str() (string, error) {
+	colon := bytes.IndexByte(d.buf[d.i:], ':')
+	if colon == -1 {
+		return "", fmt.Errorf("bencode: invalid string at offset %d", d.i)
+	}
+	s := string(d.buf[d.i : d.i+colon])
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || s != strconv.Itoa(n) {
+		return "", fmt.Errorf("bencode: invalid string length %q at offset %d", s, d.i)
+	}
+	start := d.i + colon + 1
+	if n > len(d.buf)-start {
+		return "", fmt.Errorf("bencode: string length %d at offset %d exceeds data", n, d.i)
+	}
+	d.i = start + n
+	return string(d.buf[start:d.i]), nil
+}
+
+// Encode encodes v as canonical bencode, with dictionary keys sorted. Values
+// may be integers, strings, []byte, []string, []interface{} and
+// map[string]interface{}.
+func Encode(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := encode(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode encodes v to buf.
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case int:
+		fmt.Fprintf(buf, "i%de", x)
+	case int64:
+		fmt.Fprintf(buf, "i%de", x)
+	case string:
+		buf.WriteString(strconv.Itoa(len(x)) + ":" + x)
+	case []byte:
+		buf.WriteString(strconv.Itoa(len(x)) + ":")
+		buf.Write(x)
+	case []string:
+		buf.WriteByte('l')
+		for _, s := range x {
+			_ = encode(buf, s)
+		}
+		buf.WriteByte('e')
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, y := range x {
+			if err := encode(buf, y); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			_ = encode(buf, k)
+			if err := encode(buf, x[k]); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
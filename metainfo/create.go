@@ -0,0 +1,256 @@
+package metainfo
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CreateOptions are the options for creating a torrent.
+type CreateOptions struct {
+	// Announce is the announce url.
+	Announce string
+	// PieceLength is the piece length. When zero, PieceLength is used.
+	PieceLength int64
+	// Private sets the private flag.
+	Private bool
+	// Source is the info dictionary's source.
+	Source string
+	// Concurrency is the number of pieces hashed concurrently. When zero,
+	// runtime.NumCPU is used.
+	Concurrency int
+}
+
+// PieceLength returns the piece length typically used by bhd uploads for
+// content of the size, ranging from 1 MiB for content up to 1 GiB, to 16 MiB
+// for content over 16 GiB.
+func PieceLength(size int64) int64 {
+	const mib, gib = 1 << 20, 1 << 30
+	switch {
+	case size <= 1*gib:
+		return 1 * mib
+	case size <= 4*gib:
+		return 2 * mib
+	case size <= 8*gib:
+		return 4 * mib
+	case size <= 16*gib:
+		return 8 * mib
+	}
+	return 16 * mib
+}
+
+// Create creates a bencoded v1 torrent for the file or directory at name.
+// Directory files are sorted by path, and files that are not regular files
+// are skipped.
+func Create(ctx context.Context, name string, opts CreateOptions) ([]byte, error) {
+	files, err := walk(name)
+	if err != nil {
+		return nil, err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.Length
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%s: no content", name)
+	}
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = PieceLength(size)
+	}
+	if pieceLength < 16<<10 || pieceLength&(pieceLength-1) != 0 {
+		return nil, fmt.Errorf("invalid piece length %d: must be a power of 2 of at least 16 KiB", pieceLength)
+	}
+	pieces, err := hashPieces(ctx, name, files, size, pieceLength, opts.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+	info := map[string]interface{}{
+		"name":         filepath.Base(filepath.Clean(name)),
+		"piece length": pieceLength,
+		"pieces":       pieces,
+	}
+	if len(files) == 1 && files[0].Path == "" {
+		info["length"] = files[0].Length
+	} else {
+		var list []interface{}
+		for _, f := range files {
+			list = append(list, map[string]interface{}{
+				"length": f.Length,
+				"path":   strings.Split(f.Path, "/"),
+			})
+		}
+		info["files"] = list
+	}
+	if opts.Private {
+		info["private"] = int64(1)
+	}
+	if opts.Source != "" {
+		info["source"] = opts.Source
+	}
+	dict := map[string]interface{}{
+		"info": info,
+	}
+	if opts.Announce != "" {
+		dict["announce"] = opts.Announce
+	}
+	return Encode(dict)
+}
+
+// walk returns the regular files for the file or directory at name, sorted
+// by path. A single file has an empty path.
+func walk(name string) ([]File, error) {
+	fi, err := os.Stat(name)
+	switch {
+	case err != nil:
+		return nil, err
+	case fi.Mode().IsRegular():
+		return []File{{Length: fi.Size()}}, nil
+	case !fi.IsDir():
+		return nil, fmt.Errorf("%s: not a file or directory", name)
+	}
+	var files []File
+	err = filepath.WalkDir(name, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(name, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Length: fi.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// hashPieces returns the concatenated sha1 piece hashes of the files, hashing
+// pieces concurrently.
+func hashPieces(ctx context.Context, name string, files []File, size, pieceLength int64, concurrency int) ([]byte, error) {
+	r, err := openFiles(name, files)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	n := (size + pieceLength - 1) / pieceLength
+	pieces := make([]byte, n*sha1.Size)
+	ch := make(chan int64)
+	var once sync.Once
+	var firstErr error
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, pieceLength)
+			for i := range ch {
+				off := i * pieceLength
+				b := buf[:min(pieceLength, size-off)]
+				if err := r.ReadAt(b, off); err != nil {
+					once.Do(func() { firstErr = err })
+					cancel()
+					continue
+				}
+				h := sha1.Sum(b)
+				copy(pieces[i*sha1.Size:], h[:])
+			}
+		}()
+	}
+loop:
+	for i := int64(0); i < n && ctx.Err() == nil; i++ {
+		select {
+		case ch <- i:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(ch)
+	wg.Wait()
+	switch {
+	case firstErr != nil:
+		return nil, firstErr
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	}
+	return pieces, nil
+}
+
+// multiFile reads the concatenated content of files.
+type multiFile struct {
+	files   []*os.File
+	offsets []int64
+	sizes   []int64
+}
+
+// openFiles opens the files.
+func openFiles(name string, files []File) (*multiFile, error) {
+	r := new(multiFile)
+	var off int64
+	for _, f := range files {
+		fd, err := os.Open(filepath.Join(name, filepath.FromSlash(f.Path)))
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.files = append(r.files, fd)
+		r.offsets = append(r.offsets, off)
+		r.sizes = append(r.sizes, f.Length)
+		off += f.Length
+	}
+	return r, nil
+}
+
+// ReadAt reads len(b) bytes at the offset, across file boundaries.
+func (r *multiFile) ReadAt(b []byte, off int64) error {
+	i := sort.Search(len(r.offsets), func(i int) bool {
+		return r.offsets[i]+r.sizes[i] > off
+	})
+	for ; len(b) != 0 && i < len(r.files); i++ {
+		start := off - r.offsets[i]
+		n := min(int64(len(b)), r.sizes[i]-start)
+		if _, err := r.files[i].ReadAt(b[:n], start); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("%s: file changed while hashing", r.files[i].Name())
+			}
+			return err
+		}
+		b, off = b[n:], off+n
+	}
+	if len(b) != 0 {
+		return errors.New("unexpected end of content")
+	}
+	return nil
+}
+
+// Close closes the files.
+func (r *multiFile) Close() error {
+	var errs []error
+	for _, f := range r.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
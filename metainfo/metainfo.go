@@ -0,0 +1,283 @@
+// Package metainfo reads and rewrites bencoded torrent metainfo, such as that
+// returned by bhdapi.Client.Torrent.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"text/tabwriter"
+)
+
+// ErrInfoChanged is the error returned when a rewrite would change the info
+// dictionary, and with it the info hash.
+var ErrInfoChanged = errors.New("metainfo: info dictionary changed")
+
+// Metainfo is a decoded torrent metainfo.
+type Metainfo struct {
+	dict     map[string]interface{}
+	infoHash string
+	// allowInfoChange allows edits to change the info dictionary.
+	allowInfoChange bool
+}
+
+// Parse parses the bencoded metainfo.
+func Parse(buf []byte) (*Metainfo, error) {
+	d := &decoder{buf: buf}
+	v, err := d.decode()
+	switch {
+	case err != nil:
+		return nil, err
+	case d.i != len(buf):
+		return nil, fmt.Errorf("bencode: trailing data at offset %d", d.i)
+	}
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("metainfo: expected dictionary")
+	}
+	if _, ok := dict["info"].(map[string]interface{}); !ok {
+		return nil, errors.New("metainfo: missing info dictionary")
+	}
+	h := sha1.Sum(d.info)
+	return &Metainfo{
+		dict:     dict,
+		infoHash: hex.EncodeToString(h[:]),
+	}, nil
+}
+
+// InfoHash returns the hex encoded v1 info hash of the parsed metainfo.
+func (m *Metainfo) InfoHash() string {
+	return m.infoHash
+}
+
+// info returns the info dictionary.
+func (m *Metainfo) info() map[string]interface{} {
+	info, _ := m.dict["info"].(map[string]interface{})
+	return info
+}
+
+// Name returns the torrent's name.
+func (m *Metainfo) Name() string {
+	s, _ := m.info()["name"].(string)
+	return s
+}
+
+// Announce returns the announce url.
+func (m *Metainfo) Announce() string {
+	s, _ := m.dict["announce"].(string)
+	return s
+}
+
+// AnnounceList returns the announce-list tiers.
+func (m *Metainfo) AnnounceList() [][]string {
+	var tiers [][]string
+	list, _ := m.dict["announce-list"].([]interface{})
+	for _, t := range list {
+		var tier []string
+		urls, _ := t.([]interface{})
+		for _, u := range urls {
+			if s, ok := u.(string); ok {
+				tier = append(tier, s)
+			}
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers
+}
+
+// Source returns the info dictionary's source.
+func (m *Metainfo) Source() string {
+	s, _ := m.info()["source"].(string)
+	return s
+}
+
+// Private returns true when the info dictionary's private flag is set.
+func (m *Metainfo) Private() bool {
+	n, _ := m.info()["private"].(int64)
+	return n == 1
+}
+
+// File is a file in a torrent.
+type File struct {
+	// Path is the slash separated path, relative to the torrent's name for
+	// multi file torrents.
+	Path string
+	// Length is the file length.
+	Length int64
+}
+
+// Files returns the torrent's files. Single file torrents return a single
+// file with the torrent's name as its path.
+func (m *Metainfo) Files() []File {
+	info := m.info()
+	list, ok := info["files"].([]interface{})
+	if !ok {
+		length, _ := info["length"].(int64)
+		return []File{{Path: m.Name(), Length: length}}
+	}
+	var files []File
+	for _, f := range list {
+		d, _ := f.(map[string]interface{})
+		length, _ := d["length"].(int64)
+		parts, _ := d["path"].([]interface{})
+		var elems []string
+		for _, p := range parts {
+			s, _ := p.(string)
+			elems = append(elems, s)
+		}
+		files = append(files, File{Path: path.Join(elems...), Length: length})
+	}
+	return files
+}
+
+// Size returns the total length of the torrent's files.
+func (m *Metainfo) Size() int64 {
+	var n int64
+	for _, f := range m.Files() {
+		n += f.Length
+	}
+	return n
+}
+
+// WriteFiles writes the torrent's file list to w, one file per line with its
+// size, preceded by the torrent's name and total size for multi file
+// torrents.
+func (m *Metainfo) WriteFiles(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	files := m.Files()
+	indent := ""
+	if _, ok := m.info()["files"]; ok {
+		fmt.Fprintf(tw, "%s\t%s\n", m.Name()+"/", FormatSize(m.Size()))
+		indent = "  "
+	}
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%s\n", indent+f.Path, FormatSize(f.Length))
+	}
+	return tw.Flush()
+}
+
+// Edit is a metainfo edit.
+type Edit func(m *Metainfo) error
+
+// WithAnnounce is an edit to set the announce url, removing the
+// announce-list.
+func WithAnnounce(announce string) Edit {
+	return func(m *Metainfo) error {
+		m.dict["announce"] = announce
+		delete(m.dict, "announce-list")
+		return nil
+	}
+}
+
+// WithAnnounceFunc is an edit to rewrite the announce url and each
+// announce-list url with f, as when sending announces through a proxy.
+func WithAnnounceFunc(f func(string) (string, error)) Edit {
+	return func(m *Metainfo) error {
+		if s, ok := m.dict["announce"].(string); ok {
+			u, err := f(s)
+			if err != nil {
+				return err
+			}
+			m.dict["announce"] = u
+		}
+		list, _ := m.dict["announce-list"].([]interface{})
+		for _, t := range list {
+			urls, _ := t.([]interface{})
+			for i, u := range urls {
+				s, ok := u.(string)
+				if !ok {
+					continue
+				}
+				var err error
+				if urls[i], err = f(s); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// WithSource is an edit to set the info dictionary's source. An empty source
+// removes it. Changes the info hash.
+func WithSource(source string) Edit {
+	return func(m *Metainfo) error {
+		if source == "" {
+			delete(m.info(), "source")
+		} else {
+			m.info()["source"] = source
+		}
+		return nil
+	}
+}
+
+// WithPrivate is an edit to set or remove the info dictionary's private flag.
+// Changes the info hash.
+func WithPrivate(private bool) Edit {
+	return func(m *Metainfo) error {
+		if private {
+			m.info()["private"] = int64(1)
+		} else {
+			delete(m.info(), "private")
+		}
+		return nil
+	}
+}
+
+// AllowInfoChange is an edit that allows the other edits to change the info
+// dictionary.
+func AllowInfoChange() Edit {
+	return func(m *Metainfo) error {
+		m.allowInfoChange = true
+		return nil
+	}
+}
+
+// Rewrite applies the edits to the metainfo, and re-encodes it as canonical
+// bencode, returning the rewritten metainfo and whether its info hash changed.
+// When the info hash changes, as when the edits change the info dictionary or
+// the info dictionary was not canonically encoded, ErrInfoChanged is returned
+// unless AllowInfoChange is used.
+func Rewrite(buf []byte, edits ...Edit) ([]byte, bool, error) {
+	m, err := Parse(buf)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, edit := range edits {
+		if err := edit(m); err != nil {
+			return nil, false, err
+		}
+	}
+	info, err := Encode(m.info())
+	if err != nil {
+		return nil, false, err
+	}
+	h := sha1.Sum(info)
+	changed := hex.EncodeToString(h[:]) != m.infoHash
+	if changed && !m.allowInfoChange {
+		return nil, true, ErrInfoChanged
+	}
+	out, err := Encode(m.dict)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, changed, nil
+}
+
+// FormatSize formats n bytes as a human readable size using IEC units.
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
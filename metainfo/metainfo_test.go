@@ -0,0 +1,257 @@
+package metainfo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moistari/bhdapi/bhdtest"
+)
+
+func TestParse(t *testing.T) {
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999.mkv", 38702381297)
+	m, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	switch {
+	case m.InfoHash() != exp:
+		t.Errorf("expected info hash %s, got: %s", exp, m.InfoHash())
+	case m.Name() != "Fight.Club.1999.mkv":
+		t.Errorf("expected name %q, got: %q", "Fight.Club.1999.mkv", m.Name())
+	case m.Announce() != "https://tracker.beyond-hd.me:2053/announce/passkey":
+		t.Errorf("expected announce, got: %q", m.Announce())
+	case m.Source() != "BHD" || !m.Private():
+		t.Errorf("expected private BHD source, got: %q %t", m.Source(), m.Private())
+	case m.Size() != 38702381297:
+		t.Errorf("expected size 38702381297, got: %d", m.Size())
+	}
+	w := new(strings.Builder)
+	if err := m.WriteFiles(w); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := w.String(), "Fight.Club.1999.mkv  36.0 GiB\n"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	multi := []byte("d13:announce-listll1:aee4:infod5:filesld6:lengthi1024e4:pathl3:sub5:a.mkveed6:lengthi10e4:pathl5:b.nfoeee4:name4:Pack12:piece lengthi16384e6:pieces0:ee")
+	if m, err = Parse(multi); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if tiers := m.AnnounceList(); !reflect.DeepEqual(tiers, [][]string{{"a"}}) {
+		t.Errorf("expected announce-list, got: %v", tiers)
+	}
+	w.Reset()
+	if err := m.WriteFiles(w); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if s, exp := w.String(), "Pack/        1.0 KiB\n  sub/a.mkv  1.0 KiB\n  b.nfo      10 B\n"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	for _, s := range []string{
+		"",
+		"le",
+		"d4:name1:ae",
+		"d4:infod4:name1:aeex",
+		"d4:infoi01ee",
+		"d4:infoi-0ee",
+		"d4:infod4:name01:aee",
+		"d4:info99:ae",
+		"d4:infod4:name1:a4:name1:bee",
+		"d4:infodi1e1:aee",
+		"d4:infold",
+	} {
+		if _, err := Parse([]byte(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	buf, exp := bhdtest.Metainfo("https://tracker.beyond-hd.me:2053/announce/passkey", "Fight.Club.1999.mkv", 38702381297)
+	proxy := WithAnnounceFunc(func(s string) (string, error) {
+		return strings.Replace(s, "https://tracker.beyond-hd.me:2053", "http://localhost:8080", 1), nil
+	})
+	out, changed, err := Rewrite(buf, proxy)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case changed:
+		t.Errorf("expected info hash to not change")
+	}
+	m, err := Parse(out)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case m.InfoHash() != exp:
+		t.Errorf("expected info hash %s, got: %s", exp, m.InfoHash())
+	case m.Announce() != "http://localhost:8080/announce/passkey":
+		t.Errorf("expected proxied announce, got: %q", m.Announce())
+	}
+	if _, changed, err := Rewrite(buf, WithSource("")); !errors.Is(err, ErrInfoChanged) || !changed {
+		t.Errorf("expected ErrInfoChanged, got: %t %v", changed, err)
+	}
+	out, changed, err = Rewrite(buf, WithSource(""), WithPrivate(false), AllowInfoChange())
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case !changed:
+		t.Errorf("expected info hash to change")
+	}
+	if m, err = Parse(out); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m.InfoHash() == exp || m.Source() != "" || m.Private() {
+		t.Errorf("expected public torrent without source and a new info hash, got: %s %q %t", m.InfoHash(), m.Source(), m.Private())
+	}
+	out, _, err = Rewrite(out, WithSource("BHD"), WithPrivate(true), AllowInfoChange())
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if m, _ = Parse(out); m.InfoHash() != exp {
+		t.Errorf("expected restored info hash %s, got: %s", exp, m.InfoHash())
+	}
+	// unsorted info keys are not canonical
+	unsorted := []byte("d8:announce1:a4:infod4:name1:a6:lengthi1eee")
+	if _, changed, err := Rewrite(unsorted); !errors.Is(err, ErrInfoChanged) || !changed {
+		t.Errorf("expected ErrInfoChanged for non canonical info, got: %t %v", changed, err)
+	}
+	out, _, err = Rewrite(unsorted, WithAnnounce("b"), AllowInfoChange())
+	if exp := []byte("d8:announce1:b4:infod6:lengthi1e4:name1:aee"); err != nil || !bytes.Equal(out, exp) {
+		t.Errorf("expected %q, got: %q %v", exp, out, err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	v := map[string]interface{}{
+		"b": []interface{}{int64(-1), "x", []byte{0, 1}},
+		"a": []string{"y", "z"},
+		"c": map[string]interface{}{},
+	}
+	buf, err := Encode(v)
+	if exp := "d1:al1:y1:ze1:bli-1e1:x2:\x00\x01e1:cdee"; err != nil || string(buf) != exp {
+		t.Fatalf("expected %q, got: %q %v", exp, buf, err)
+	}
+	x, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if y, _ := Encode(x); !bytes.Equal(y, buf) {
+		t.Errorf("expected round trip %q, got: %q", buf, y)
+	}
+	if _, err := Encode(map[string]interface{}{"a": 1.5}); err == nil {
+		t.Errorf("expected error for unsupported type")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Fight.Club.1999")
+	r := rand.New(rand.NewSource(1999))
+	var content []byte
+	for _, f := range []struct {
+		path string
+		size int
+	}{
+		// sorted by path
+		{"Fight.Club.1999.mkv", 200_000},
+		{"Fight.Club.1999.nfo", 1234},
+		{"Sample/empty.txt", 0},
+		{"Sample/sample.mkv", 50_000},
+	} {
+		buf := make([]byte, f.size)
+		r.Read(buf)
+		content = append(content, buf...)
+		name := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		if err := os.WriteFile(name, buf, 0o644); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	const pieceLength = 32 << 10
+	var pieces []byte
+	for off := 0; off < len(content); off += pieceLength {
+		h := sha1.Sum(content[off:min(off+pieceLength, len(content))])
+		pieces = append(pieces, h[:]...)
+	}
+	info, _ := Encode(map[string]interface{}{
+		"files": []interface{}{
+			map[string]interface{}{"length": 200_000, "path": []string{"Fight.Club.1999.mkv"}},
+			map[string]interface{}{"length": 1234, "path": []string{"Fight.Club.1999.nfo"}},
+			map[string]interface{}{"length": 0, "path": []string{"Sample", "empty.txt"}},
+			map[string]interface{}{"length": 50_000, "path": []string{"Sample", "sample.mkv"}},
+		},
+		"name":         "Fight.Club.1999",
+		"piece length": pieceLength,
+		"pieces":       pieces,
+		"private":      1,
+		"source":       "BHD",
+	})
+	h := sha1.Sum(info)
+	exp := hex.EncodeToString(h[:])
+	ctx := context.Background()
+	for _, concurrency := range []int{1, 3, 8} {
+		buf, err := Create(ctx, dir, CreateOptions{
+			Announce:    "https://tracker.beyond-hd.me:2053/announce/passkey",
+			PieceLength: pieceLength,
+			Private:     true,
+			Source:      "BHD",
+			Concurrency: concurrency,
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		m, err := Parse(buf)
+		switch {
+		case err != nil:
+			t.Fatalf("expected no error, got: %v", err)
+		case m.InfoHash() != exp:
+			t.Errorf("concurrency %d expected info hash %s, got: %s", concurrency, exp, m.InfoHash())
+		case m.Size() != int64(len(content)) || len(m.Files()) != 4 || m.Files()[3].Path != "Sample/sample.mkv":
+			t.Errorf("expected files, got: %v", m.Files())
+		}
+	}
+	// single file with the default piece length
+	name := filepath.Join(dir, "Fight.Club.1999.nfo")
+	buf, err := Create(ctx, name, CreateOptions{})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	m, err := Parse(buf)
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case m.Name() != "Fight.Club.1999.nfo" || m.Size() != 1234 || m.Announce() != "" || m.Private():
+		t.Errorf("expected public single file torrent, got: %q %d", m.Name(), m.Size())
+	}
+	if _, err := Create(ctx, dir, CreateOptions{PieceLength: 1000}); err == nil {
+		t.Errorf("expected invalid piece length error")
+	}
+	if _, err := Create(ctx, filepath.Join(dir, "Sample", "empty.txt"), CreateOptions{}); err == nil {
+		t.Errorf("expected no content error")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Create(canceled, dir, CreateOptions{PieceLength: 16 << 10}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got: %v", err)
+	}
+	for size, exp := range map[int64]int64{
+		700 << 20: 1 << 20,
+		3 << 30:   2 << 20,
+		6 << 30:   4 << 20,
+		12 << 30:  8 << 20,
+		60 << 30:  16 << 20,
+	} {
+		if n := PieceLength(size); n != exp {
+			t.Errorf("expected piece length %d for %d, got: %d", exp, size, n)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package bhdapi
+
+//go:generate go run gen.go
+
+// Params are the params for a BHD api action.
+type Params interface {
+	// Action returns the api action.
+	Action() string
+	// Encode encodes the params' fields for the api request.
+	Encode() map[string]interface{}
+}
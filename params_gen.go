@@ -0,0 +1,169 @@
+// Code generated by gen.go. DO NOT EDIT.
+
+package bhdapi
+
+import "strings"
+
+// Action satisfies the Params interface.
+func (req *SearchRequest) Action() string {
+	return "search"
+}
+
+// Encode satisfies the Params interface. Zero value fields are omitted.
+func (req *SearchRequest) Encode() map[string]interface{} {
+	m := make(map[string]interface{})
+	if req.Search != "" {
+		m["search"] = req.Search
+	}
+	if req.InfoHash != "" {
+		m["info_hash"] = req.InfoHash
+	}
+	if req.FolderName != "" {
+		m["folder_name"] = req.FolderName
+	}
+	if req.FileName != "" {
+		m["file_name"] = req.FileName
+	}
+	if req.Size != 0 {
+		m["size"] = req.Size
+	}
+	if req.UploadedBy != "" {
+		m["uploaded_by"] = req.UploadedBy
+	}
+	if req.ImdbID != "" {
+		m["imdb_id"] = req.ImdbID
+	}
+	if req.TmdbID != "" {
+		m["tmdb_id"] = req.TmdbID
+	}
+	if len(req.Categories) != 0 {
+		m["categories"] = strings.Join(req.Categories, ",")
+	}
+	if len(req.Types) != 0 {
+		m["types"] = strings.Join(req.Types, ",")
+	}
+	if len(req.Sources) != 0 {
+		m["sources"] = strings.Join(req.Sources, ",")
+	}
+	if len(req.Genres) != 0 {
+		m["genres"] = strings.Join(req.Genres, ",")
+	}
+	if len(req.Groups) != 0 {
+		m["groups"] = strings.Join(req.Groups, ",")
+	}
+	if req.Freeleech {
+		m["freeleech"] = req.Freeleech.Int()
+	}
+	if req.Limited {
+		m["limited"] = req.Limited.Int()
+	}
+	if req.Promo25 {
+		m["promo25"] = req.Promo25.Int()
+	}
+	if req.Promo50 {
+		m["promo50"] = req.Promo50.Int()
+	}
+	if req.Promo75 {
+		m["promo75"] = req.Promo75.Int()
+	}
+	if req.Refund {
+		m["refund"] = req.Refund.Int()
+	}
+	if req.Rescue {
+		m["rescue"] = req.Rescue.Int()
+	}
+	if req.Rewind {
+		m["rewind"] = req.Rewind.Int()
+	}
+	if req.Stream {
+		m["stream"] = req.Stream.Int()
+	}
+	if req.SD {
+		m["sd"] = req.SD.Int()
+	}
+	if req.Pack {
+		m["pack"] = req.Pack.Int()
+	}
+	if req.H264 {
+		m["h_264"] = req.H264.Int()
+	}
+	if req.H265 {
+		m["h_265"] = req.H265.Int()
+	}
+	if len(req.Features) != 0 {
+		m["features"] = strings.Join(req.Features, ",")
+	}
+	if req.Alive {
+		m["alive"] = req.Alive.Int()
+	}
+	if req.Dying {
+		m["dying"] = req.Dying.Int()
+	}
+	if req.Dead {
+		m["dead"] = req.Dead.Int()
+	}
+	if req.Reseed {
+		m["reseed"] = req.Reseed.Int()
+	}
+	if req.Seeding {
+		m["seeding"] = req.Seeding.Int()
+	}
+	if req.Leeching {
+		m["leeching"] = req.Leeching.Int()
+	}
+	if req.Completed {
+		m["completed"] = req.Completed.Int()
+	}
+	if req.Incomplete {
+		m["incomplete"] = req.Incomplete.Int()
+	}
+	if req.NotDownloaded {
+		m["notdownloaded"] = req.NotDownloaded.Int()
+	}
+	if req.MinBHD != 0 {
+		m["min_bhd"] = req.MinBHD
+	}
+	if req.VoteBHD != 0 {
+		m["vote_bhd"] = req.VoteBHD
+	}
+	if req.MinImdb != 0 {
+		m["min_imdb"] = req.MinImdb
+	}
+	if req.VoteImdb != 0 {
+		m["vote_imdb"] = req.VoteImdb
+	}
+	if req.MinTmbd != 0 {
+		m["min_tmdb"] = req.MinTmbd
+	}
+	if req.VoteTmbd != 0 {
+		m["vote_tmdb"] = req.VoteTmbd
+	}
+	if req.MinYear != 0 {
+		m["min_year"] = req.MinYear
+	}
+	if req.MaxYear != 0 {
+		m["max_year"] = req.MaxYear
+	}
+	if len(req.Countries) != 0 {
+		m["countries"] = strings.Join(req.Countries, ",")
+	}
+	if len(req.Languages) != 0 {
+		m["languages"] = strings.Join(req.Languages, ",")
+	}
+	if len(req.Audios) != 0 {
+		m["audios"] = strings.Join(req.Audios, ",")
+	}
+	if len(req.Subtitles) != 0 {
+		m["subtitles"] = strings.Join(req.Subtitles, ",")
+	}
+	if req.Sort != "" {
+		m["sort"] = req.Sort
+	}
+	if req.Order != "" {
+		m["order"] = req.Order
+	}
+	if req.Page != 0 {
+		m["page"] = req.Page
+	}
+	return m
+}
@@ -0,0 +1,214 @@
+package bhdapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SearchDir returns the saved search directory
+// ($XDG_CONFIG_HOME/bhdapi/searches).
+func SearchDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "searches"), nil
+}
+
+// searchExts are the saved search file extensions, in order of precedence.
+var searchExts = []string{".yaml", ".yml", ".json"}
+
+// searchNameRE matches valid saved search names.
+var searchNameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// SaveSearch saves the public fields of the search request as a named search
+// in the saved search directory, in YAML format. An existing saved search
+// with the same name is replaced.
+func SaveSearch(name string, req *SearchRequest) error {
+	if !searchNameRE.MatchString(name) {
+		return fmt.Errorf("invalid saved search name %q", name)
+	}
+	buf, err := MarshalSearch(req, "yaml")
+	if err != nil {
+		return err
+	}
+	dir, err := SearchDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := DeleteSearch(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name+".yaml"), buf, 0o644)
+}
+
+// LoadSearch loads the named search from the saved search directory.
+//
+// Example:
+//
+//	req, err := bhdapi.LoadSearch("uhd-dv-freeleech")
+//	if err != nil {
+//		/* ... */
+//	}
+//	res, err := req.WithMinYear(2020).Do(ctx, cl)
+func LoadSearch(name string) (*SearchRequest, error) {
+	path, err := searchFile(name)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := UnmarshalSearch(buf, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return req, nil
+}
+
+// ListSearches returns the sorted names of the saved searches.
+func ListSearches() ([]string, error) {
+	dir, err := SearchDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if entry.IsDir() || !contains(searchExts, ext) || !searchNameRE.MatchString(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteSearch deletes the named search from the saved search directory.
+func DeleteSearch(name string) error {
+	path, err := searchFile(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// searchFile returns the path to the named search's file.
+func searchFile(name string) (string, error) {
+	if !searchNameRE.MatchString(name) {
+		return "", fmt.Errorf("invalid saved search name %q", name)
+	}
+	dir, err := SearchDir()
+	if err != nil {
+		return "", err
+	}
+	for _, ext := range searchExts {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("saved search %q: %w", name, os.ErrNotExist)
+}
+
+// MarshalSearch marshals the public fields of the search request in the
+// format (json or yaml). Field names are the search request's json tags.
+func MarshalSearch(req *SearchRequest, format string) ([]byte, error) {
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "json":
+		out := new(bytes.Buffer)
+		if err := json.Indent(out, buf, "", "  "); err != nil {
+			return nil, err
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+	case "yaml", "yml":
+		var m map[string]interface{}
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return nil, err
+		}
+		// use yaml bools instead of 1
+		typ := reflect.TypeOf(req).Elem()
+		for i := 0; i < typ.NumField(); i++ {
+			tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]
+			if _, ok := m[tag]; ok && typ.Field(i).Type == reflect.TypeOf(Bool(false)) {
+				m[tag] = true
+			}
+		}
+		out := new(bytes.Buffer)
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(m); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// UnmarshalSearch unmarshals a search request in the format (json or yaml)
+// as created by MarshalSearch. Unknown fields are an error.
+func UnmarshalSearch(buf []byte, format string) (*SearchRequest, error) {
+	switch format {
+	case "json":
+	case "yaml", "yml":
+		var m map[string]interface{}
+		if err := yaml.Unmarshal(buf, &m); err != nil {
+			return nil, err
+		}
+		var err error
+		if buf, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	req := Search()
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// contains returns true when v is in s.
+func contains(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,378 @@
+// Package quality ranks bhd releases by quality using configurable profiles,
+// and finds upgrades for the releases held in a library.
+package quality
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is a release kind.
+type Kind string
+
+// Release kinds.
+const (
+	Disc   Kind = "disc"
+	Remux  Kind = "remux"
+	Encode Kind = "encode"
+	WEB    Kind = "web"
+)
+
+// Quality is a release's quality.
+type Quality struct {
+	// Resolution is the resolution, such as 2160p or 1080p.
+	Resolution string `json:"resolution,omitempty"`
+	// Kind is the release kind.
+	Kind Kind `json:"kind,omitempty"`
+	// Features are the video features (DV, HDR10, HDR10P, HLG).
+	Features []string `json:"features,omitempty"`
+	// Audio is the best audio format, such as TrueHD Atmos or DTS-HD MA.
+	Audio string `json:"audio,omitempty"`
+	// Group is the release group.
+	Group string `json:"group,omitempty"`
+	// Internal is true for internal releases.
+	Internal bool `json:"internal,omitempty"`
+}
+
+// String satisfies the fmt.Stringer interface.
+func (q Quality) String() string {
+	var v []string
+	for _, s := range append([]string{q.Resolution, string(q.Kind)}, q.Features...) {
+		if s != "" {
+			v = append(v, s)
+		}
+	}
+	if q.Audio != "" {
+		v = append(v, q.Audio)
+	}
+	if q.Group != "" {
+		v = append(v, "-"+q.Group)
+	}
+	return strings.Join(v, " ")
+}
+
+// Has returns true when the quality has the feature.
+func (q Quality) Has(feature string) bool {
+	for _, f := range q.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// matcher is a name matcher.
+type matcher struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// features are the video feature matchers, in Quality.Features order.
+var features = []matcher{
+	{"DV", regexp.MustCompile(`(?i)\b(?:dv|dovi|dolby vision)\b`)},
+	{"HDR10", regexp.MustCompile(`(?i)\bhdr(?:10)?\b`)},
+	{"HDR10P", regexp.MustCompile(`(?i)\bhdr10(?:\+|p\b|plus\b)`)},
+	{"HLG", regexp.MustCompile(`(?i)\bhlg\b`)},
+}
+
+// audios are the audio matchers, in order of precedence.
+var audios = []matcher{
+	{"TrueHD Atmos", regexp.MustCompile(`(?i)\btruehd\b.*\batmos\b|\batmos\b.*\btruehd\b`)},
+	{"DTS:X", regexp.MustCompile(`(?i)\bdts[:-]?x\b`)},
+	{"DTS-HD MA", regexp.MustCompile(`(?i)\bdts-?hd ma\b`)},
+	{"TrueHD", regexp.MustCompile(`(?i)\btruehd\b`)},
+	{"LPCM", regexp.MustCompile(`(?i)\bl?pcm\b`)},
+	{"FLAC", regexp.MustCompile(`(?i)\bflac\b`)},
+	{"DD+ Atmos", regexp.MustCompile(`(?i)\b(?:dd\+|ddp|e-?ac-?3).*\batmos\b`)},
+	{"DTS-HD", regexp.MustCompile(`(?i)\bdts-?hd\b`)},
+	{"DD+", regexp.MustCompile(`(?i)\b(?:dd\+|ddp|e-?ac-?3)`)},
+	{"DTS", regexp.MustCompile(`(?i)\bdts\b`)},
+	{"DD", regexp.MustCompile(`(?i)\b(?:dd|ac-?3)\b`)},
+	{"AAC", regexp.MustCompile(`(?i)\baac\b`)},
+	{"Opus", regexp.MustCompile(`(?i)\bopus\b`)},
+}
+
+// Audios are the known audio formats, in order of precedence.
+var Audios = func() []string {
+	var v []string
+	for _, m := range audios {
+		v = append(v, m.name)
+	}
+	return v
+}()
+
+var (
+	uhdRE    = regexp.MustCompile(`(?i)\b(?:uhd|4k)\b`)
+	remuxRE  = regexp.MustCompile(`(?i)\bremux\b`)
+	webRE    = regexp.MustCompile(`(?i)\bweb(?:-?dl|-?rip)?\b`)
+	encodeRE = regexp.MustCompile(`(?i)\b(?:x26[45]|[bh]drip|brrip|encode)\b`)
+	discRE   = regexp.MustCompile(`(?i)\b(?:complete|full disc|bd(?:25|50|66|100)|uhd(?:50|66|100)|dvd[59])\b|\bblu-?ray\b.*\b(?:avc|hevc|vc-1|mpeg-2)\b`)
+	groupRE  = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+)
+
+// normalize replaces the dots and underscores in a release name with spaces.
+func normalize(name string) string {
+	return strings.NewReplacer(".", " ", "_", " ").Replace(strings.TrimSpace(name))
+}
+
+// Parse parses the quality of a release name, such as a bhd torrent or
+// folder name. Quality descriptions such as "1080p remux DTS-HD MA" are also
+// parsed.
+func Parse(name string) Quality {
+	s := normalize(name)
+	q := Quality{
+		Resolution: bhdapi.ParseResolution(s),
+		Kind:       parseKind(s),
+		Audio:      parseAudio(s),
+	}
+	if q.Resolution == "" && uhdRE.MatchString(s) {
+		q.Resolution = "2160p"
+	}
+	for _, m := range features {
+		if m.re.MatchString(s) {
+			q.Features = append(q.Features, m.name)
+		}
+	}
+	if m := groupRE.FindStringSubmatch(strings.TrimSpace(name)); m != nil {
+		q.Group = m[1]
+	}
+	q.Internal = isInternal(q.Group)
+	return q
+}
+
+// parseKind parses the release kind of the normalized name.
+func parseKind(s string) Kind {
+	switch {
+	case remuxRE.MatchString(s):
+		return Remux
+	case webRE.MatchString(s):
+		return WEB
+	case encodeRE.MatchString(s):
+		return Encode
+	case discRE.MatchString(s):
+		return Disc
+	case bhdapi.ParseResolution(s) != "":
+		return Encode
+	}
+	return ""
+}
+
+// parseAudio parses the best audio format of the normalized name.
+func parseAudio(s string) string {
+	for _, m := range audios {
+		if m.re.MatchString(s) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+// isInternal returns true when group is a known internal group.
+func isInternal(group string) bool {
+	for _, g := range bhdapi.Groups {
+		if group != "" && strings.EqualFold(g, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromTorrent returns the quality of a bhd torrent, using its type and
+// feature flags, and falling back to its name.
+func FromTorrent(t bhdapi.Torrent) Quality {
+	q := Parse(t.Name)
+	switch typ := t.Type; {
+	case strings.HasSuffix(typ, " Remux"):
+		q.Kind = Remux
+	case strings.HasPrefix(typ, "UHD "), strings.HasPrefix(typ, "BD "), strings.HasPrefix(typ, "DVD "):
+		q.Kind = Disc
+	}
+	if res := t.Resolution(); res != "" {
+		q.Resolution = res
+	}
+	if slices.Contains(bhdapi.Resolutions, t.Type) && (q.Kind == "" || q.Kind == Remux || q.Kind == Disc) {
+		q.Kind = Encode
+	}
+	flags := map[string]bhdapi.Bool{
+		"DV":     t.DV,
+		"HDR10":  t.HDR10,
+		"HDR10P": t.HDR10P,
+		"HLG":    t.HLG,
+	}
+	q.Features = nil
+	for _, m := range features {
+		if bool(flags[m.name]) || m.re.MatchString(normalize(t.Name)) {
+			q.Features = append(q.Features, m.name)
+		}
+	}
+	q.Internal = q.Internal || bool(t.Internal)
+	return q
+}
+
+// Profile is a quality profile, assigning weights to each aspect of a
+// release's quality. A release's rank is the sum of its weights.
+type Profile struct {
+	// Resolutions are the resolution weights, keyed by resolution (2160p,
+	// 1080p, ...).
+	Resolutions map[string]int `json:"resolutions,omitempty"`
+	// Kinds are the release kind weights, keyed by kind (disc, remux,
+	// encode, web).
+	Kinds map[Kind]int `json:"kinds,omitempty"`
+	// Features are the video feature weights, keyed by feature (DV, HDR10,
+	// HDR10P, HLG).
+	Features map[string]int `json:"features,omitempty"`
+	// Audio are the audio format weights, keyed by format (see Audios).
+	Audio map[string]int `json:"audio,omitempty"`
+	// Groups are the release group weights, keyed by group.
+	Groups map[string]int `json:"groups,omitempty"`
+	// Internal is the weight of internal releases.
+	Internal int `json:"internal,omitempty"`
+	// Types are the torrent type weights, keyed by type (see bhdapi.Types).
+	Types map[string]int `json:"types,omitempty"`
+	// Categories are the torrent category weights, keyed by category (see
+	// bhdapi.Categories).
+	Categories map[string]int `json:"categories,omitempty"`
+	// Promos are the promo weights, keyed by promo (see Promos).
+	Promos map[string]int `json:"promos,omitempty"`
+	// Seeders are the seeder count bands.
+	Seeders []Band `json:"seeders,omitempty"`
+	// Sizes are the size bands, in GiB.
+	Sizes []Band `json:"sizes,omitempty"`
+	// Ratings are the weights per rating point, keyed by rating (bhd, imdb,
+	// tmdb).
+	Ratings map[string]float64 `json:"ratings,omitempty"`
+}
+
+// DefaultProfile returns the default quality profile, preferring higher
+// resolutions above all else, then remuxes, then video features and lossless
+// audio. Dead and poorly seeded torrents are penalized when scored.
+func DefaultProfile() *Profile {
+	return &Profile{
+		Resolutions: map[string]int{
+			"2160p": 4000,
+			"1080p": 3000,
+			"1080i": 2500,
+			"720p":  2000,
+			"576p":  1000,
+			"540p":  900,
+			"480p":  800,
+		},
+		Kinds: map[Kind]int{
+			Remux:  400,
+			Disc:   300,
+			WEB:    150,
+			Encode: 100,
+		},
+		Features: map[string]int{
+			"DV":     60,
+			"HDR10P": 40,
+			"HDR10":  30,
+			"HLG":    10,
+		},
+		Audio: map[string]int{
+			"TrueHD Atmos": 50,
+			"DTS:X":        45,
+			"DTS-HD MA":    40,
+			"TrueHD":       35,
+			"LPCM":         30,
+			"FLAC":         25,
+			"DD+ Atmos":    20,
+			"DTS-HD":       15,
+			"DD+":          10,
+			"DTS":          8,
+			"DD":           5,
+			"AAC":          2,
+			"Opus":         2,
+		},
+		Internal: 20,
+		Seeders: []Band{
+			{Max: 1, Weight: -1000},
+			{Min: 1, Max: 5, Weight: -50},
+		},
+	}
+}
+
+// Rank returns the rank of the quality, the sum of the profile's resolution,
+// kind, feature, audio, group and internal weights.
+func (p *Profile) Rank(q Quality) int {
+	var n float64
+	for _, r := range p.explain(q) {
+		n += r.Weight
+	}
+	return int(n)
+}
+
+// explain returns the reasons for the rank of the quality.
+func (p *Profile) explain(q Quality) []Reason {
+	var reasons []Reason
+	add := func(field, value string, weight int) {
+		if weight != 0 {
+			reasons = append(reasons, Reason{field, value, float64(weight)})
+		}
+	}
+	add("resolution", q.Resolution, p.Resolutions[q.Resolution])
+	add("kind", string(q.Kind), p.Kinds[q.Kind])
+	for _, f := range q.Features {
+		add("feature", f, p.Features[f])
+	}
+	add("audio", q.Audio, p.Audio[q.Audio])
+	for g, w := range p.Groups {
+		if q.Group != "" && strings.EqualFold(g, q.Group) {
+			add("group", q.Group, w)
+		}
+	}
+	if q.Internal {
+		add("internal", "", p.Internal)
+	}
+	return reasons
+}
+
+// ReadProfile reads a quality profile from the named file, in yaml (.yaml,
+// .yml) or json (.json) format. Unknown fields are an error.
+func ReadProfile(name string) (*Profile, error) {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	format := strings.TrimPrefix(filepath.Ext(name), ".")
+	p, err := UnmarshalProfile(buf, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return p, nil
+}
+
+// UnmarshalProfile unmarshals a quality profile in the format (json or
+// yaml). Unknown fields are an error.
+func UnmarshalProfile(buf []byte, format string) (*Profile, error) {
+	switch format {
+	case "json":
+	case "yaml", "yml":
+		var m map[string]interface{}
+		if err := yaml.Unmarshal(buf, &m); err != nil {
+			return nil, err
+		}
+		var err error
+		if buf, err = json.Marshal(m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	p := new(Profile)
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
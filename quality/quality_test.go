@@ -0,0 +1,179 @@
+package quality
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/moistari/bhdapi"
+	"github.com/moistari/bhdapi/bhdtest"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  Quality
+	}{
+		{"", Quality{}},
+		{"1080p remux", Quality{Resolution: "1080p", Kind: Remux}},
+		{"The Matrix 1999 720p WEB-DL DD 5.1 H.264-NTb", Quality{Resolution: "720p", Kind: WEB, Audio: "DD", Group: "NTb"}},
+		{"Severance.S01.2160p.ATVP.WEB-DL.DDP.5.1.DV.HDR.H.265-NTb", Quality{Resolution: "2160p", Kind: WEB, Features: []string{"DV", "HDR10"}, Audio: "DD+", Group: "NTb"}},
+		{"Fight Club 1999 UHD BluRay 2160p TrueHD Atmos 7.1 DV HEVC REMUX-FraMeSToR", Quality{Resolution: "2160p", Kind: Remux, Features: []string{"DV"}, Audio: "TrueHD Atmos", Group: "FraMeSToR", Internal: true}},
+		{"Movie 2010 1080p Blu-ray AVC DTS-HD MA 5.1-GRP", Quality{Resolution: "1080p", Kind: Disc, Audio: "DTS-HD MA", Group: "GRP"}},
+		{"Movie 2019 UHD BluRay x265 HDR10+ DTS:X-GRP", Quality{Resolution: "2160p", Kind: Encode, Features: []string{"HDR10", "HDR10P"}, Audio: "DTS:X", Group: "GRP"}},
+		{"Movie.2021.1080p.WEB-DL.DDP5.1.Atmos.H.264-GRP", Quality{Resolution: "1080p", Kind: WEB, Audio: "DD+ Atmos", Group: "GRP"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if q := Parse(test.name); !reflect.DeepEqual(q, test.exp) {
+				t.Errorf("expected %+v, got: %+v", test.exp, q)
+			}
+		})
+	}
+	if s, exp := tests[4].exp.String(), "2160p remux DV TrueHD Atmos -FraMeSToR"; s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := bhdapi.New(bhdapi.WithApiKey(srv.ApiKey), bhdapi.WithTransport(srv.Transport()))
+	torrents, err := bhdapi.Search().All(context.Background(), cl)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	p := DefaultProfile()
+	ranks := make(map[int]int)
+	for _, torrent := range torrents {
+		ranks[torrent.ID] = p.Rank(FromTorrent(torrent))
+	}
+	exp := map[int]int{
+		7531:   3460,
+		101233: 4560,
+		150012: 3130,
+		162200: 4530,
+		170555: 2155,
+		180777: 4250,
+	}
+	if !reflect.DeepEqual(ranks, exp) {
+		t.Errorf("expected %v, got: %v", exp, ranks)
+	}
+	p, err = UnmarshalProfile([]byte("resolutions:\n  1080p: 10\nkinds:\n  remux: 5\ngroups:\n  ntb: 3\n"), "yaml")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if n := p.Rank(Parse("Movie 1080p REMUX-NTb")); n != 18 {
+		t.Errorf("expected 18, got: %d", n)
+	}
+	if _, err := UnmarshalProfile([]byte(`{"resolution": {}}`), "json"); err == nil {
+		t.Errorf("expected unknown field error")
+	}
+}
+
+func TestUpgrades(t *testing.T) {
+	entries, err := ReadLibrary(strings.NewReader(`imdb_id,tmdb_id,title,release,notes
+0137523,,Fight Club,Fight.Club.1999.BluRay.1080p.DTS-HD.MA.5.1.AVC.REMUX-FraMeSToR,
+tt0133093,,The Matrix,The.Matrix.1999.UHD.BluRay.2160p.DTS-HD.MA.5.1.HDR10+.HEVC.REMUX-FraMeSToR,
+,95396,Severance,1080p web,rewatch
+tt0137523,,Fight Club,1080p remux,
+`))
+	switch {
+	case err != nil:
+		t.Fatalf("expected no error, got: %v", err)
+	case len(entries) != 4 || entries[0].ImdbID != "tt0137523" || entries[2].TmdbID != "95396":
+		t.Fatalf("expected entries, got: %+v", entries)
+	}
+	for _, s := range []string{
+		"",
+		"title,release\nFight Club,1080p\n",
+		"imdb_id,title\n,Fight Club\n",
+		"imdb_id\n\"tt\n",
+	} {
+		if _, err := ReadLibrary(strings.NewReader(s)); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := bhdapi.New(bhdapi.WithApiKey(srv.ApiKey), bhdapi.WithTransport(srv.Transport()))
+	upgrades, err := Upgrades(context.Background(), cl, DefaultProfile(), entries)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var ids [][]int
+	for _, u := range upgrades {
+		var v []int
+		for _, c := range u.Candidates {
+			v = append(v, c.Torrent.ID)
+		}
+		ids = append(ids, v)
+	}
+	// the 1080p remux description is only compared on resolution and kind, so
+	// the 1080p remux 7531 is not an upgrade
+	if exp := [][]int{{101233}, {180777}, {101233}}; !reflect.DeepEqual(ids, exp) {
+		t.Errorf("expected %v, got: %v", exp, ids)
+	}
+	if u := upgrades[0]; u.Entry.Title != "Fight Club" || u.Rank != 3460 || u.Candidates[0].Rank != 4560 {
+		t.Errorf("expected fight club upgrade, got: %+v", u)
+	}
+	if u := upgrades[2]; u.Rank != 3400 || u.Candidates[0].Rank != 4400 {
+		t.Errorf("expected 1080p remux upgrade, got: %+v", u)
+	}
+	if n := srv.Requests("search"); n != 4 {
+		t.Errorf("expected 4 search requests, got: %d", n)
+	}
+}
+
+func TestScore(t *testing.T) {
+	srv := bhdtest.NewServer("0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210")
+	defer srv.Close()
+	cl := bhdapi.New(bhdapi.WithApiKey(srv.ApiKey), bhdapi.WithTransport(srv.Transport()))
+	torrents, err := bhdapi.Search().All(context.Background(), cl)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	p, err := UnmarshalProfile([]byte(`types: {UHD Remux: 100, 1080p: 10}
+categories: {TV: -20}
+features: {DV: 50}
+internal: 5
+promos: {freeleech: 30, promo50: 15}
+seeders: [{max: 1, weight: -1000}, {min: 100, weight: 20}]
+sizes: [{min: 60, weight: -10}]
+ratings: {bhd: 2}
+`), "yaml")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	scores := p.Sort(torrents)
+	var ids []int
+	var totals []float64
+	for _, s := range scores {
+		ids = append(ids, s.Torrent.ID)
+		totals = append(totals, math.Round(s.Total*10)/10)
+	}
+	if exp := []int{101233, 162200, 180777, 150012, 7531, 170555}; !reflect.DeepEqual(ids, exp) {
+		t.Errorf("expected %v, got: %v", exp, ids)
+	}
+	if exp := []float64{213.2, 163.6, 47.8, 46, 42.2, 12.2}; !reflect.DeepEqual(totals, exp) {
+		t.Errorf("expected %v, got: %v", exp, totals)
+	}
+	exp := "feature DV +50, internal +5, type UHD Remux +100, promo freeleech +30, seeders 241 +20, size 66.4 GiB -10, rating bhd 9.1 +18.2"
+	if s := scores[0].Explain(); s != exp {
+		t.Errorf("expected %q, got: %q", exp, s)
+	}
+	torrents = append(torrents, bhdapi.Torrent{ID: 1, Name: "a"}, bhdapi.Torrent{ID: 2, Name: "b"})
+	ids = nil
+	for _, s := range p.Best(torrents) {
+		ids = append(ids, s.Torrent.ID)
+	}
+	if exp := []int{101233, 162200, 180777, 1, 2}; !reflect.DeepEqual(ids, exp) {
+		t.Errorf("expected %v, got: %v", exp, ids)
+	}
+	s := DefaultProfile().Score(bhdapi.Torrent{Name: "Movie 1080p WEB-DL AAC-GRP"})
+	if exp := "resolution 1080p +3000, kind web +150, audio AAC +2, seeders 0 -1000"; s.Explain() != exp || s.Total != 2152 {
+		t.Errorf("expected %q, got: %q %g", exp, s.Explain(), s.Total)
+	}
+}
@@ -0,0 +1,168 @@
+package quality
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+)
+
+// Promos are the known promos.
+var Promos = []string{
+	"freeleech",
+	"promo25",
+	"promo50",
+	"promo75",
+	"rewind",
+	"refund",
+	"limited",
+	"rescue",
+}
+
+// Band is a weight applied to values in the range [Min, Max). A zero Max is
+// unbounded.
+type Band struct {
+	Min    float64 `json:"min,omitempty"`
+	Max    float64 `json:"max,omitempty"`
+	Weight int     `json:"weight"`
+}
+
+// contains returns true when v is in the band's range.
+func (b Band) contains(v float64) bool {
+	return b.Min <= v && (b.Max == 0 || v < b.Max)
+}
+
+// Reason is a weight contributing to a score.
+type Reason struct {
+	// Field is the scored field, such as resolution, type or seeders.
+	Field string `json:"field"`
+	// Value is the field's value.
+	Value string `json:"value,omitempty"`
+	// Weight is the weight added to the score.
+	Weight float64 `json:"weight"`
+}
+
+// String satisfies the fmt.Stringer interface.
+func (r Reason) String() string {
+	s := r.Field
+	if r.Value != "" {
+		s += " " + r.Value
+	}
+	if r.Weight > 0 {
+		s += " +"
+	} else {
+		s += " "
+	}
+	return s + strconv.FormatFloat(r.Weight, 'f', -1, 64)
+}
+
+// Score is a torrent's score, with the reasons for it.
+type Score struct {
+	Torrent bhdapi.Torrent `json:"torrent"`
+	Quality Quality        `json:"quality"`
+	Total   float64        `json:"total"`
+	Reasons []Reason       `json:"reasons"`
+}
+
+// Explain returns the score's reasons as a comma separated string.
+func (s Score) Explain() string {
+	v := make([]string, len(s.Reasons))
+	for i, r := range s.Reasons {
+		v[i] = r.String()
+	}
+	return strings.Join(v, ", ")
+}
+
+// Score scores the torrent, summing the profile's weights for the torrent's
+// quality (see Rank), type, category, promos, seeders, size and ratings.
+func (p *Profile) Score(t bhdapi.Torrent) Score {
+	q := FromTorrent(t)
+	s := Score{
+		Torrent: t,
+		Quality: q,
+		Reasons: p.explain(q),
+	}
+	add := func(field, value string, weight float64) {
+		if weight != 0 {
+			s.Reasons = append(s.Reasons, Reason{field, value, weight})
+		}
+	}
+	add("type", t.Type, float64(p.Types[t.Type]))
+	add("category", t.Category, float64(p.Categories[t.Category]))
+	for i, b := range []bhdapi.Bool{t.Freeleech, t.Promo25, t.Promo50, t.Promo75, t.Rewind, t.Refund, t.Limited, t.Rescue} {
+		if b {
+			add("promo", Promos[i], float64(p.Promos[Promos[i]]))
+		}
+	}
+	for _, b := range p.Seeders {
+		if b.contains(float64(t.Seeders)) {
+			add("seeders", strconv.Itoa(t.Seeders), float64(b.Weight))
+		}
+	}
+	for _, b := range p.Sizes {
+		if gib := float64(t.Size) / (1 << 30); b.contains(gib) {
+			add("size", fmt.Sprintf("%.1f GiB", gib), float64(b.Weight))
+		}
+	}
+	for _, r := range []struct {
+		name   string
+		rating float64
+	}{
+		{"bhd", t.BhdRating},
+		{"imdb", t.ImdbRating},
+		{"tmdb", t.TmdbRating},
+	} {
+		add("rating", r.name+" "+strconv.FormatFloat(r.rating, 'f', -1, 64), p.Ratings[r.name]*r.rating)
+	}
+	for _, r := range s.Reasons {
+		s.Total += r.Weight
+	}
+	return s
+}
+
+// Sort scores the torrents, returning the scores ordered by total, then by
+// seeders.
+func (p *Profile) Sort(torrents []bhdapi.Torrent) []Score {
+	scores := make([]Score, len(torrents))
+	for i, t := range torrents {
+		scores[i] = p.Score(t)
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		a, b := scores[i], scores[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Torrent.Seeders > b.Torrent.Seeders
+	})
+	return scores
+}
+
+// Best returns the top scored torrent for each title, ordered as by Sort.
+// Titles are identified by imdb id, falling back to the tmdb id, and then to
+// the torrent's id.
+func (p *Profile) Best(torrents []bhdapi.Torrent) []Score {
+	var best []Score
+	seen := make(map[string]bool)
+	for _, s := range p.Sort(torrents) {
+		key := titleKey(s.Torrent)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		best = append(best, s)
+	}
+	return best
+}
+
+// titleKey returns the key identifying the torrent's title.
+func titleKey(t bhdapi.Torrent) string {
+	switch {
+	case t.ImdbID != "":
+		return "imdb:" + t.ImdbID
+	case t.TmdbID != "":
+		return "tmdb:" + t.TmdbID
+	}
+	return "id:" + strconv.Itoa(t.ID)
+}
@@ -0,0 +1,184 @@
+package quality
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/moistari/bhdapi"
+)
+
+// Entry is a library entry, identifying a title by its imdb or tmdb id, and
+// the release held.
+type Entry struct {
+	// ImdbID is the imdb id (tt0137523).
+	ImdbID string `json:"imdb_id,omitempty"`
+	// TmdbID is the tmdb id.
+	TmdbID string `json:"tmdb_id,omitempty"`
+	// Title is the title, used only for display.
+	Title string `json:"title,omitempty"`
+	// Release is the name or quality description of the release held, such
+	// as "Fight.Club.1999.1080p.BluRay.x264-GROUP" or "1080p remux". Empty
+	// when no release is held.
+	Release string `json:"release,omitempty"`
+}
+
+// Quality returns the quality of the release held.
+func (e Entry) Quality() Quality {
+	return Parse(e.Release)
+}
+
+// compared returns the parts of q compared with the release held. A release
+// name (with a -GROUP suffix), or no release, is compared on all parts. A
+// quality description, such as "1080p remux", is only compared on the
+// resolution, kind, features and audio it names, as the parts it omits are
+// not known.
+func (e Entry) compared(q Quality) Quality {
+	held := e.Quality()
+	if e.Release == "" || held.Group != "" {
+		return q
+	}
+	var c Quality
+	if held.Resolution != "" {
+		c.Resolution = q.Resolution
+	}
+	if held.Kind != "" {
+		c.Kind = q.Kind
+	}
+	if len(held.Features) != 0 {
+		c.Features = q.Features
+	}
+	if held.Audio != "" {
+		c.Audio = q.Audio
+	}
+	return c
+}
+
+// ReadLibrary reads library entries from csv. The first record is a header
+// naming the columns: imdb_id, tmdb_id, title and release. Either imdb_id or
+// tmdb_id is required, other columns are optional, and unknown columns are
+// ignored. Numeric imdb ids are prefixed with tt.
+func ReadLibrary(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, errors.New("library: missing header")
+	case err != nil:
+		return nil, fmt.Errorf("library: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, s := range header {
+		cols[strings.ToLower(strings.TrimSpace(s))] = i
+	}
+	_, imdb := cols["imdb_id"]
+	_, tmdb := cols["tmdb_id"]
+	if !imdb && !tmdb {
+		return nil, errors.New("library: header must contain imdb_id or tmdb_id")
+	}
+	get := func(record []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var entries []Entry
+	for {
+		record, err := cr.Read()
+		switch {
+		case errors.Is(err, io.EOF):
+			return entries, nil
+		case err != nil:
+			return nil, fmt.Errorf("library: %w", err)
+		}
+		e := Entry{
+			ImdbID:  get(record, "imdb_id"),
+			TmdbID:  get(record, "tmdb_id"),
+			Title:   get(record, "title"),
+			Release: get(record, "release"),
+		}
+		if e.ImdbID != "" && strings.Trim(e.ImdbID, "0123456789") == "" {
+			e.ImdbID = "tt" + e.ImdbID
+		}
+		if e.ImdbID == "" && e.TmdbID == "" {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("library: line %d: missing imdb_id or tmdb_id", line)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Candidate is a ranked release. The rank is of the parts compared with the
+// release held (see Upgrades).
+type Candidate struct {
+	Torrent bhdapi.Torrent `json:"torrent"`
+	Quality Quality        `json:"quality"`
+	Rank    int            `json:"rank"`
+}
+
+// Upgrade is a library entry with the releases that outrank the release
+// held.
+type Upgrade struct {
+	Entry   Entry   `json:"entry"`
+	Quality Quality `json:"quality"`
+	Rank    int     `json:"rank"`
+	// Candidates are the releases that outrank the release held, ordered by
+	// rank, then by seeders.
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Upgrades searches for each library entry by its imdb id (or tmdb id when
+// the imdb id is not set), returning the entries with releases that outrank
+// the release held using the profile. When the release held is a quality
+// description, such as "1080p remux", releases are only ranked on the
+// resolution, kind, features and audio the description names, so that a
+// release with the same resolution and kind is not an upgrade because of
+// parts the description does not mention.
+func Upgrades(ctx context.Context, cl *bhdapi.Client, p *Profile, entries []Entry) ([]Upgrade, error) {
+	var upgrades []Upgrade
+	for _, e := range entries {
+		req := bhdapi.Search()
+		switch {
+		case e.ImdbID != "":
+			req = req.WithImdbID(e.ImdbID)
+		case e.TmdbID != "":
+			req = req.WithTmdbID(e.TmdbID)
+		default:
+			return nil, fmt.Errorf("entry %q: missing imdb_id or tmdb_id", e.Title)
+		}
+		torrents, err := req.All(ctx, cl)
+		if err != nil {
+			return nil, err
+		}
+		q := e.Quality()
+		u := Upgrade{
+			Entry:   e,
+			Quality: q,
+			Rank:    p.Rank(q),
+		}
+		for _, t := range torrents {
+			q := FromTorrent(t)
+			if rank := p.Rank(e.compared(q)); rank > u.Rank {
+				u.Candidates = append(u.Candidates, Candidate{t, q, rank})
+			}
+		}
+		if len(u.Candidates) == 0 {
+			continue
+		}
+		sort.SliceStable(u.Candidates, func(i, j int) bool {
+			a, b := u.Candidates[i], u.Candidates[j]
+			if a.Rank != b.Rank {
+				return a.Rank > b.Rank
+			}
+			return a.Torrent.Seeders > b.Torrent.Seeders
+		})
+		upgrades = append(upgrades, u)
+	}
+	return upgrades, nil
+}
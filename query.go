@@ -0,0 +1,434 @@
+package bhdapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ParseQuery parses a search query into a search request.
+//
+// A query is a space separated list of terms. Terms that are not key:value
+// pairs, comparisons or flags are free text, and are added to the request's
+// Search field, including !negative terms. Values containing spaces, and free
+// text that would otherwise be parsed as a term, are double quoted, with \"
+// and \\ escapes:
+//
+//	fight club type:"BD Remux",1080p source:Blu-ray group:FraMeSToR
+//
+// Only known keys are parsed as keys, and other words containing a colon,
+// such as "2001:" or "re:zero", are free text.
+//
+// Keys for list fields (category, type, source, genre, group, feature,
+// country, language, audio, subtitle) accept comma separated values, and may
+// be repeated. Keys for numeric ranges (year, imdb, tmdb, bhd, imdb_votes,
+// tmdb_votes, bhd_votes) accept a range or a comparison:
+//
+//	year:1995..2005 year:1999 year>=1995 imdb>=7 bhd:8.. imdb_votes>1000
+//
+// Ratings (imdb, tmdb, bhd) are whole numbers compared to the minimum rating,
+// so imdb>7 is the same as imdb>=7. For year and votes, year>1999 is the same
+// as year>=2000.
+//
+// The imdb and tmdb keys without a range or comparison match the IMDb or TMDb
+// ID (imdb:tt0137523). Flags are written as is:freeleech, is:pack, etc., and
+// the promo flags freeleech, promo25, promo50 and promo75 may be written
+// without the is: prefix. Other keys are info_hash, folder, file, size,
+// uploader, sort, order and page.
+//
+// Errors are returned as a *QueryError with the position of the invalid
+// term. Whitespace in free text is collapsed.
+func ParseQuery(query string) (*SearchRequest, error) {
+	req := Search()
+	p := &queryParser{s: query, v: reflect.ValueOf(req).Elem()}
+	var search []string
+	for {
+		p.skip()
+		if p.i >= len(p.s) {
+			break
+		}
+		text, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if text != "" {
+			search = append(search, text)
+		}
+	}
+	req.Search = strings.Join(strings.Fields(strings.Join(search, " ")), " ")
+	return req, nil
+}
+
+// QueryError is a query parse error.
+type QueryError struct {
+	// Query is the query.
+	Query string
+	// Pos is the byte offset of the invalid term or value in the query.
+	Pos int
+	// Msg is the error message.
+	Msg string
+}
+
+// Error satisfies the error interface.
+func (err *QueryError) Error() string {
+	return fmt.Sprintf("query position %d: %s", err.Pos, err.Msg)
+}
+
+// queryKey is a query key's search request fields.
+type queryKey struct {
+	// field is the field for key:value terms.
+	field string
+	// min and max are the fields for ranges and comparisons.
+	min, max string
+	// rating is true for rating keys, for which > and < are the same as >=
+	// and <=.
+	rating bool
+}
+
+// queryKeys are the query keys, in format order. The first key for a field is
+// the key used by Query.
+var queryKeys = []struct {
+	names []string
+	key   queryKey
+}{
+	{[]string{"category", "categories", "cat"}, queryKey{field: "Categories"}},
+	{[]string{"type", "types"}, queryKey{field: "Types"}},
+	{[]string{"source", "sources"}, queryKey{field: "Sources"}},
+	{[]string{"genre", "genres"}, queryKey{field: "Genres"}},
+	{[]string{"group", "groups"}, queryKey{field: "Groups"}},
+	{[]string{"feature", "features"}, queryKey{field: "Features"}},
+	{[]string{"year"}, queryKey{min: "MinYear", max: "MaxYear"}},
+	{[]string{"imdb"}, queryKey{field: "ImdbID", min: "MinImdb", rating: true}},
+	{[]string{"tmdb"}, queryKey{field: "TmdbID", min: "MinTmbd", rating: true}},
+	{[]string{"bhd"}, queryKey{min: "MinBHD", rating: true}},
+	{[]string{"imdb_votes"}, queryKey{min: "VoteImdb"}},
+	{[]string{"tmdb_votes"}, queryKey{min: "VoteTmbd"}},
+	{[]string{"bhd_votes"}, queryKey{min: "VoteBHD"}},
+	{[]string{"country", "countries"}, queryKey{field: "Countries"}},
+	{[]string{"language", "languages", "lang"}, queryKey{field: "Languages"}},
+	{[]string{"audio", "audios"}, queryKey{field: "Audios"}},
+	{[]string{"subtitle", "subtitles", "sub", "subs"}, queryKey{field: "Subtitles"}},
+	{[]string{"info_hash", "hash"}, queryKey{field: "InfoHash"}},
+	{[]string{"folder", "folder_name"}, queryKey{field: "FolderName"}},
+	{[]string{"file", "file_name"}, queryKey{field: "FileName"}},
+	{[]string{"size"}, queryKey{field: "Size"}},
+	{[]string{"uploader", "uploaded_by"}, queryKey{field: "UploadedBy"}},
+	{[]string{"sort"}, queryKey{field: "Sort"}},
+	{[]string{"order"}, queryKey{field: "Order"}},
+	{[]string{"page"}, queryKey{field: "Page"}},
+}
+
+// promoFlags are the flags that may be written without the is: prefix.
+var promoFlags = []string{"freeleech", "promo25", "promo50", "promo75"}
+
+// lookupKey returns the query key for name.
+func lookupKey(name string) (queryKey, bool) {
+	for _, k := range queryKeys {
+		if contains(k.names, name) {
+			return k.key, true
+		}
+	}
+	return queryKey{}, false
+}
+
+// flagField returns the index of the Bool field for the flag name. Flag names
+// are the field's json tag without underscores.
+func flagField(name string) int {
+	typ := reflect.TypeOf(SearchRequest{})
+	for i := 0; i < typ.NumField(); i++ {
+		tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]
+		if typ.Field(i).Type == reflect.TypeOf(Bool(false)) && strings.ReplaceAll(tag, "_", "") == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// queryParser is a query parser.
+type queryParser struct {
+	s string
+	i int
+	v reflect.Value
+}
+
+// errorf returns a query error at pos.
+func (p *queryParser) errorf(pos int, format string, v ...interface{}) error {
+	return &QueryError{Query: p.s, Pos: pos, Msg: fmt.Sprintf(format, v...)}
+}
+
+// skip skips whitespace.
+func (p *queryParser) skip() {
+	for p.i < len(p.s) && isSpace(p.s[p.i]) {
+		p.i++
+	}
+}
+
+// term parses the next term, returning free text.
+func (p *queryParser) term() (string, error) {
+	start := p.i
+	if p.s[p.i] == '"' {
+		return p.quoted()
+	}
+	// key
+	for p.i < len(p.s) && isKeyChar(p.s[p.i]) {
+		p.i++
+	}
+	name := p.s[start:p.i]
+	key, ok := lookupKey(name)
+	op := p.op()
+	if !ok && name != "is" {
+		// unknown keys are free text
+		op = ""
+	}
+	if name == "" || op == "" {
+		// free text or flag
+		for p.i < len(p.s) && !isSpace(p.s[p.i]) {
+			p.i++
+		}
+		word := p.s[start:p.i]
+		if contains(promoFlags, word) {
+			p.v.Field(flagField(word)).SetBool(true)
+			return "", nil
+		}
+		return word, nil
+	}
+	valuePos := p.i
+	values, err := p.values()
+	if err != nil {
+		return "", err
+	}
+	if name == "is" {
+		if op != ":" || len(values) != 1 {
+			return "", p.errorf(start, "expected is:flag")
+		}
+		i := flagField(values[0])
+		if i == -1 {
+			return "", p.errorf(valuePos, "unknown flag %q", values[0])
+		}
+		p.v.Field(i).SetBool(true)
+		return "", nil
+	}
+	raw := p.s[valuePos:p.i]
+	isRange := key.min != "" && !strings.HasPrefix(raw, `"`) && strings.Contains(raw, "..")
+	switch {
+	case len(values) == 0:
+		return "", p.errorf(valuePos, "missing value for %s", name)
+	case (op == ":" || op == "=") && key.field != "" && !isRange:
+		return "", p.set(valuePos, name, key.field, values)
+	case key.min == "":
+		return "", p.errorf(start, "%s does not support ranges or comparisons", name)
+	case len(values) != 1:
+		return "", p.errorf(valuePos, "expected single value for %s", name)
+	}
+	var lo, hi string
+	switch op {
+	case ":", "=":
+		var ok bool
+		if lo, hi, ok = strings.Cut(values[0], ".."); !ok && key.max != "" {
+			hi = lo
+		}
+		if lo == "" && hi == "" {
+			return "", p.errorf(valuePos, "invalid range for %s", name)
+		}
+	case ">=", ">":
+		lo = values[0]
+	case "<=", "<":
+		hi = values[0]
+	}
+	if hi != "" && key.max == "" {
+		return "", p.errorf(start, "%s does not support a maximum", name)
+	}
+	for _, r := range []struct{ field, s string }{{key.min, lo}, {key.max, hi}} {
+		if r.s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(r.s)
+		if err != nil {
+			return "", p.errorf(valuePos, "invalid number %q for %s", r.s, name)
+		}
+		switch {
+		case key.rating:
+		case op == ">":
+			n++
+		case op == "<":
+			n--
+		}
+		p.v.FieldByName(r.field).SetInt(int64(n))
+	}
+	return "", nil
+}
+
+// op parses a key:value or comparison operator.
+func (p *queryParser) op() string {
+	for _, op := range []string{":", ">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(p.s[p.i:], op) {
+			p.i += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// values parses a comma separated list of values.
+func (p *queryParser) values() ([]string, error) {
+	var values []string
+	for p.i < len(p.s) && !isSpace(p.s[p.i]) {
+		var value string
+		if p.s[p.i] == '"' {
+			var err error
+			if value, err = p.quoted(); err != nil {
+				return nil, err
+			}
+		} else {
+			start := p.i
+			for p.i < len(p.s) && !isSpace(p.s[p.i]) && p.s[p.i] != ',' && p.s[p.i] != '"' {
+				p.i++
+			}
+			value = p.s[start:p.i]
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+		switch {
+		case p.i < len(p.s) && p.s[p.i] == ',':
+			p.i++
+		case p.i < len(p.s) && !isSpace(p.s[p.i]):
+			return nil, p.errorf(p.i, "expected comma or space")
+		}
+	}
+	return values, nil
+}
+
+// quoted parses a double quoted string.
+func (p *queryParser) quoted() (string, error) {
+	start := p.i
+	var b strings.Builder
+	for p.i++; p.i < len(p.s); p.i++ {
+		switch c := p.s[p.i]; {
+		case c == '"':
+			p.i++
+			return b.String(), nil
+		case c == '\\' && p.i+1 < len(p.s):
+			p.i++
+			b.WriteByte(p.s[p.i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf(start, "unterminated quoted string")
+}
+
+// set sets the named field to the values.
+func (p *queryParser) set(pos int, name, field string, values []string) error {
+	f := p.v.FieldByName(field)
+	switch f.Interface().(type) {
+	case []string:
+		f.Set(reflect.AppendSlice(f, reflect.ValueOf(values)))
+		return nil
+	}
+	if len(values) != 1 {
+		return p.errorf(pos, "expected single value for %s", name)
+	}
+	switch f.Interface().(type) {
+	case string:
+		f.SetString(values[0])
+	case int, int64:
+		n, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil || f.OverflowInt(n) {
+			return p.errorf(pos, "invalid number %q for %s", values[0], name)
+		}
+		f.SetInt(n)
+	}
+	return nil
+}
+
+// Query formats the search request as a query, as parsed by ParseQuery.
+// Unexported fields are not included.
+func (req *SearchRequest) Query() string {
+	var terms []string
+	for _, word := range strings.Fields(req.Search) {
+		if r, err := ParseQuery(word); err != nil || r.Search != word {
+			word = quote(word)
+		}
+		terms = append(terms, word)
+	}
+	v := reflect.ValueOf(req).Elem()
+	for _, k := range queryKeys {
+		name := k.names[0]
+		if k.key.field != "" {
+			switch f := v.FieldByName(k.key.field); x := f.Interface().(type) {
+			case []string:
+				var values []string
+				for _, s := range x {
+					if s != "" {
+						values = append(values, quoteValue(s))
+					}
+				}
+				if len(values) != 0 {
+					terms = append(terms, name+":"+strings.Join(values, ","))
+				}
+			case string:
+				if x != "" {
+					terms = append(terms, name+":"+quoteValue(x))
+				}
+			default:
+				if name == "page" && f.Int() != 1 || name != "page" && !f.IsZero() {
+					terms = append(terms, fmt.Sprintf("%s:%d", name, f.Int()))
+				}
+			}
+		}
+		var lo, hi int64
+		if k.key.min != "" {
+			lo = v.FieldByName(k.key.min).Int()
+		}
+		if k.key.max != "" {
+			hi = v.FieldByName(k.key.max).Int()
+		}
+		switch {
+		case lo != 0 && lo == hi:
+			terms = append(terms, fmt.Sprintf("%s:%d", name, lo))
+		case lo != 0 && hi != 0:
+			terms = append(terms, fmt.Sprintf("%s:%d..%d", name, lo, hi))
+		case lo != 0:
+			terms = append(terms, fmt.Sprintf("%s>=%d", name, lo))
+		case hi != 0:
+			terms = append(terms, fmt.Sprintf("%s<=%d", name, hi))
+		}
+	}
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).Type != reflect.TypeOf(Bool(false)) || !v.Field(i).Bool() {
+			continue
+		}
+		name := strings.ReplaceAll(strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0], "_", "")
+		if !contains(promoFlags, name) {
+			name = "is:" + name
+		}
+		terms = append(terms, name)
+	}
+	return strings.Join(terms, " ")
+}
+
+// quoteValue quotes a key:value value when necessary.
+func quoteValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\",\\") || strings.Contains(s, "..") {
+		return quote(s)
+	}
+	return s
+}
+
+// quote double quotes s.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// isSpace returns true when c is a space.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isKeyChar returns true when c is valid in a key.
+func isKeyChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '_'
+}
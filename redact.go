@@ -0,0 +1,84 @@
+package bhdapi
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redacted is the replacement for redacted values.
+const redacted = "[redacted]"
+
+// Redact redacts the api and rss keys from any BHD urls contained in s, such
+// as a Torrent's DownloadURL.
+func Redact(s string) string {
+	for _, re := range redactRes {
+		s = re.ReplaceAllString(s, "${1}"+redacted)
+	}
+	return s
+}
+
+// redactRes are the regexps for key positions in BHD urls.
+var redactRes = []*regexp.Regexp{
+	// api urls: /api/torrents/<apikey>
+	regexp.MustCompile(`(/api/[a-z]+/)[^/?#\s"'\[]+`),
+	// download urls: /torrent/download/<name>.<id>.<rsskey>
+	regexp.MustCompile(`(/torrent/download/[^/?#\s"']*\.[0-9]+\.)[^/?#\s"'.\[]+`),
+	// query parameters
+	regexp.MustCompile(`(?i)([?&](?:rsskey|passkey|apikey|api_key)=)[^&#\s"'\[]+`),
+}
+
+// Redact redacts the client's api and rss keys from s.
+func (cl *Client) Redact(s string) string {
+	s = Redact(s)
+	for _, key := range []string{cl.ApiKey, cl.RssKey} {
+		if key != "" {
+			s = strings.ReplaceAll(s, key, redacted)
+		}
+	}
+	return s
+}
+
+// redactErr wraps err so that the client's keys are redacted from the error
+// message. The url of any wrapped *url.Error is redacted in place.
+func (cl *Client) redactErr(err error) error {
+	return redactErr(err, cl.Redact)
+}
+
+// redactErr wraps err so that redact is applied to the error message of err
+// and every error it wraps. The url of any wrapped *url.Error is redacted in
+// place.
+func redactErr(err error, redact func(string) string) error {
+	if err == nil {
+		return nil
+	}
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		ue.URL = redact(ue.URL)
+	}
+	s := err.Error()
+	if r := redact(s); r != s {
+		return &RedactedError{msg: r, err: err, redact: redact}
+	}
+	return err
+}
+
+// RedactedError is an error with secrets redacted from its message.
+type RedactedError struct {
+	msg    string
+	err    error
+	redact func(string) string
+}
+
+// Error satisfies the error interface.
+func (err *RedactedError) Error() string {
+	return err.msg
+}
+
+// Unwrap satisfies the errors.Unwrap interface. Returns the error wrapped by
+// the original error, itself redacted when its message contains secrets, so
+// that secrets are not exposed anywhere in the chain.
+func (err *RedactedError) Unwrap() error {
+	return redactErr(errors.Unwrap(err.err), err.redact)
+}
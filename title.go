@@ -0,0 +1,278 @@
+package bhdapi
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Title is a title's torrents, grouped into editions by resolution.
+type Title struct {
+	// ImdbID is the imdb id.
+	ImdbID string `json:"imdb_id,omitempty"`
+	// TmdbID is the tmdb id.
+	TmdbID string `json:"tmdb_id,omitempty"`
+	// Name is the title's name, parsed from the first torrent's name.
+	Name string `json:"name"`
+	// Year is the title's year, parsed from the first torrent's name.
+	Year int `json:"year,omitempty"`
+	// Category is the first torrent's category.
+	Category string `json:"category,omitempty"`
+	// Editions are the editions, ordered by resolution, highest first.
+	Editions []*Edition `json:"editions"`
+	Stats
+}
+
+// Edition is a title's torrents of a resolution, grouped into release sets by
+// type.
+type Edition struct {
+	// Resolution is the resolution (see Torrent.Resolution).
+	Resolution string `json:"resolution"`
+	// Sets are the release sets, ordered as Types.
+	Sets []*ReleaseSet `json:"sets"`
+	Stats
+}
+
+// ReleaseSet is an edition's torrents of a type.
+type ReleaseSet struct {
+	// Type is the type.
+	Type string `json:"type"`
+	// Torrents are the torrents, in the order grouped.
+	Torrents []Torrent `json:"torrents"`
+	Stats
+}
+
+// Stats are aggregates of a group of torrents.
+type Stats struct {
+	// Count is the number of torrents.
+	Count int `json:"count"`
+	// Seeders is the highest seeder count.
+	Seeders int `json:"seeders"`
+	// MinSize is the smallest size.
+	MinSize int64 `json:"min_size"`
+	// MaxSize is the largest size.
+	MaxSize int64 `json:"max_size"`
+	// Features are the features available from any torrent, ordered as
+	// TorrentFeatures.
+	Features []string `json:"features,omitempty"`
+}
+
+// add adds the torrent to the stats.
+func (s *Stats) add(t Torrent) {
+	if s.Count == 0 || t.Size < s.MinSize {
+		s.MinSize = t.Size
+	}
+	s.Count++
+	s.Seeders = max(s.Seeders, t.Seeders)
+	s.MaxSize = max(s.MaxSize, t.Size)
+	for _, f := range t.Features() {
+		if !slices.Contains(s.Features, f) {
+			s.Features = append(s.Features, f)
+			slices.SortFunc(s.Features, func(a, b string) int {
+				return order(TorrentFeatures, a) - order(TorrentFeatures, b)
+			})
+		}
+	}
+}
+
+// TorrentFeatures are the torrent feature flags, as returned by
+// Torrent.Features.
+var TorrentFeatures = []string{
+	"DV",
+	"HDR10",
+	"HDR10P",
+	"HLG",
+	"Commentary",
+}
+
+// Features returns the names of the torrent's set feature flags, ordered as
+// TorrentFeatures.
+func (t Torrent) Features() []string {
+	var v []string
+	for i, b := range []Bool{t.DV, t.HDR10, t.HDR10P, t.HLG, t.Commentary} {
+		if b {
+			v = append(v, TorrentFeatures[i])
+		}
+	}
+	return v
+}
+
+// Resolutions are the known resolutions, highest first.
+var Resolutions = []string{
+	"2160p",
+	"1080p",
+	"1080i",
+	"720p",
+	"576p",
+	"540p",
+	"480p",
+}
+
+// resolutionRE matches resolutions in torrent names.
+var resolutionRE = regexp.MustCompile(`(?i)\b(2160p|1080p|1080i|720p|576p|540p|480p)\b`)
+
+// Resolution returns the torrent's resolution, determined by its type, or its
+// name for the Other type. Returns an empty string when the resolution is not
+// known.
+func (t Torrent) Resolution() string {
+	switch {
+	case strings.HasPrefix(t.Type, "UHD "):
+		return "2160p"
+	case strings.HasPrefix(t.Type, "BD "):
+		return "1080p"
+	case strings.HasPrefix(t.Type, "DVD "):
+		return "480p"
+	case slices.Contains(Resolutions, t.Type):
+		return t.Type
+	}
+	return ParseResolution(t.Name)
+}
+
+// ParseResolution returns the lower case resolution in a release name, or an
+// empty string when the name has no resolution.
+func ParseResolution(name string) string {
+	return strings.ToLower(resolutionRE.FindString(name))
+}
+
+// tokenRE matches the space or dot separated tokens of a torrent name.
+var tokenRE = regexp.MustCompile(`[^ .]+`)
+
+// yearRE matches a year token.
+var yearRE = regexp.MustCompile(`^\(?((?:19|20)\d{2})\)?$`)
+
+// seasonRE matches a season or episode token.
+var seasonRE = regexp.MustCompile(`^S\d{2}(?:E\d{2})?$`)
+
+// tagRE matches the resolution and source tokens following the title and
+// year.
+var tagRE = regexp.MustCompile(`(?i)^(?:2160p|1080p|1080i|720p|576p|540p|480p|UHD|BluRay|Blu-ray|BDRip|BRRip|WEB-DL|WEBRip|WEB|HDTV|DVD|DVDRip|HD-DVD|REMUX)$`)
+
+// ParseTitle parses the title name and year from a torrent name, such as
+// "Fight Club 1999 BluRay 1080p ..." or "Severance S01 2160p ...". The year is
+// the last year before the season, resolution or source, so that years in the
+// title are part of the name ("Blade Runner 2049 2017 2160p ..."), and is 0
+// when not present. When no year or season is found, the name up to the
+// resolution is returned.
+func ParseTitle(name string) (string, int) {
+	end, year := -1, 0
+	for i, loc := range tokenRE.FindAllStringIndex(name, -1) {
+		token := name[loc[0]:loc[1]]
+		if i != 0 && seasonRE.MatchString(token) {
+			if end == -1 {
+				end = loc[0]
+			}
+			break
+		}
+		if tagRE.MatchString(token) {
+			break
+		}
+		if m := yearRE.FindStringSubmatch(token); m != nil && i != 0 {
+			end = loc[0]
+			year, _ = strconv.Atoi(m[1])
+		}
+	}
+	if end != -1 {
+		return strings.TrimSpace(strings.ReplaceAll(name[:end], ".", " ")), year
+	}
+	if loc := resolutionRE.FindStringIndex(name); loc != nil && loc[0] != 0 {
+		name = name[:loc[0]]
+	}
+	return strings.TrimSpace(strings.ReplaceAll(name, ".", " ")), 0
+}
+
+// Titles groups the torrents by title, identified by imdb or tmdb id, falling
+// back to the title name and year parsed from the torrent name. Torrents are
+// not grouped with a title that has a different imdb or tmdb id. Each title's
+// torrents are grouped into editions by resolution, and then into release
+// sets by type. Titles are ordered by their first torrent.
+func Titles(torrents []Torrent) []*Title {
+	var titles []*Title
+	index := make(map[string]*Title)
+	for _, t := range torrents {
+		name, year := ParseTitle(t.Name)
+		keys := []string{"name:" + strings.ToLower(name) + ":" + strconv.Itoa(year)}
+		if t.TmdbID != "" {
+			keys = append([]string{"tmdb:" + t.TmdbID}, keys...)
+		}
+		if t.ImdbID != "" {
+			keys = append([]string{"imdb:" + t.ImdbID}, keys...)
+		}
+		var title *Title
+		for _, key := range keys {
+			if title = index[key]; title != nil && !title.conflicts(t) {
+				break
+			}
+			title = nil
+		}
+		if title == nil {
+			title = &Title{
+				Name:     name,
+				Year:     year,
+				Category: t.Category,
+			}
+			titles = append(titles, title)
+		}
+		for _, key := range keys {
+			if index[key] == nil {
+				index[key] = title
+			}
+		}
+		if title.ImdbID == "" {
+			title.ImdbID = t.ImdbID
+		}
+		if title.TmdbID == "" {
+			title.TmdbID = t.TmdbID
+		}
+		title.add(t)
+	}
+	return titles
+}
+
+// conflicts returns true when the title and torrent have different imdb or
+// tmdb ids.
+func (title *Title) conflicts(t Torrent) bool {
+	return title.ImdbID != "" && t.ImdbID != "" && title.ImdbID != t.ImdbID ||
+		title.TmdbID != "" && t.TmdbID != "" && title.TmdbID != t.TmdbID
+}
+
+// add adds the torrent to the title's edition and release set.
+func (title *Title) add(t Torrent) {
+	title.Stats.add(t)
+	res := t.Resolution()
+	isEdition := func(e *Edition) bool {
+		return e.Resolution == res
+	}
+	i := slices.IndexFunc(title.Editions, isEdition)
+	if i == -1 {
+		title.Editions = append(title.Editions, &Edition{Resolution: res})
+		slices.SortStableFunc(title.Editions, func(a, b *Edition) int {
+			return order(Resolutions, a.Resolution) - order(Resolutions, b.Resolution)
+		})
+		i = slices.IndexFunc(title.Editions, isEdition)
+	}
+	e := title.Editions[i]
+	e.Stats.add(t)
+	isSet := func(s *ReleaseSet) bool {
+		return s.Type == t.Type
+	}
+	j := slices.IndexFunc(e.Sets, isSet)
+	if j == -1 {
+		e.Sets = append(e.Sets, &ReleaseSet{Type: t.Type})
+		slices.SortStableFunc(e.Sets, func(a, b *ReleaseSet) int {
+			return order(Types, a.Type) - order(Types, b.Type)
+		})
+		j = slices.IndexFunc(e.Sets, isSet)
+	}
+	s := e.Sets[j]
+	s.Stats.add(t)
+	s.Torrents = append(s.Torrents, t)
+}
+
+// order returns the index of v in s, or len(s) when v is not in s.
+func order(s []string, v string) int {
+	if i := slices.Index(s, v); i != -1 {
+		return i
+	}
+	return len(s)
+}
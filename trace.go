@@ -0,0 +1,59 @@
+package bhdapi
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Tracer is the interface for tracing client operations. See the bhdotel
+// package for an OpenTelemetry implementation.
+type Tracer interface {
+	// Start starts a span with the name, returning a context containing the
+	// span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced client operation.
+type Span interface {
+	// SetAttributes sets the span's attributes.
+	SetAttributes(attrs ...slog.Attr)
+	// End ends the span, recording the error, if any.
+	End(err error)
+}
+
+// WithTracer is a client option to set the tracer used.
+func WithTracer(tracer Tracer) Option {
+	return func(cl *Client) {
+		cl.Tracer = tracer
+	}
+}
+
+// start starts a span with the client's tracer. The span is stored in the
+// returned context, for use by spanAttrs.
+func (cl *Client) start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if cl.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, span := cl.Tracer.Start(ctx, name)
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// spanAttrs sets attributes on the span in the context, if any.
+func spanAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		span.SetAttributes(attrs...)
+	}
+}
+
+// spanKey is the context key for the current span.
+type spanKey struct{}
+
+// noopSpan is a span that does nothing.
+type noopSpan struct{}
+
+// SetAttributes satisfies the Span interface.
+func (noopSpan) SetAttributes(...slog.Attr) {}
+
+// End satisfies the Span interface.
+func (noopSpan) End(error) {}
@@ -0,0 +1,80 @@
+package bhdapi
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Values returns the search request's fields as url values, using the same
+// encoding as Encode: slices are comma separated, and bools are 0 or 1. Zero
+// value fields are omitted.
+//
+// Example:
+//
+//	link := "https://example.com/search?" + req.Values().Encode()
+func (req *SearchRequest) Values() url.Values {
+	q := make(url.Values)
+	for k, v := range req.Encode() {
+		q.Set(k, fmt.Sprint(v))
+	}
+	return q
+}
+
+// ParseSearchValues parses a search request from url values, as created by
+// Values. Slice values may be comma separated, or repeated. Unknown keys are
+// an error.
+func ParseSearchValues(q url.Values) (*SearchRequest, error) {
+	req := Search()
+	v := reflect.ValueOf(req).Elem()
+	typ := v.Type()
+	fields := make(map[string]int)
+	for i := 0; i < v.NumField(); i++ {
+		tag := strings.SplitN(typ.Field(i).Tag.Get("json"), ",", 2)[0]
+		if tag == "-" || tag == "" {
+			continue
+		}
+		fields[tag] = i
+	}
+	for key, values := range q {
+		i, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown search field %q", key)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		f, s := v.Field(i), values[len(values)-1]
+		switch f.Interface().(type) {
+		case []string:
+			var list []string
+			for _, value := range values {
+				for _, x := range strings.Split(value, ",") {
+					if x != "" {
+						list = append(list, x)
+					}
+				}
+			}
+			f.Set(reflect.ValueOf(list))
+		case string:
+			f.SetString(s)
+		case Bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value %q", key, s)
+			}
+			f.SetBool(b)
+		case int, int64:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || f.OverflowInt(n) {
+				return nil, fmt.Errorf("invalid %s value %q", key, s)
+			}
+			f.SetInt(n)
+		default:
+			return nil, fmt.Errorf("unsupported type %s for %s", f.Type(), key)
+		}
+	}
+	return req, nil
+}